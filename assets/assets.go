@@ -0,0 +1,10 @@
+// Package assets holds the artwork of the app that is built into it
+package assets
+
+import "embed"
+
+// FIPIcons are the icons drawn on the Flight Instrument Panel pages, one PNG per icon in fip/.
+// They are white on a transparent background, so they can be drawn in any colour.
+//
+//go:embed fip/*.png
+var FIPIcons embed.FS
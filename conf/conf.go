@@ -6,8 +6,6 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
-	"golang.org/x/sys/windows/registry"
-
 	"gopkg.in/yaml.v2"
 )
 
@@ -16,6 +14,85 @@ type Conf struct {
 	JournalsFolder string
 	RefreshRateMS  int
 	Pages          map[string]bool `yaml:"pages"` // Add this line
+	Web            WebConf         `yaml:"web"`
+	Serial         SerialConf      `yaml:"serial"`
+	Helper         HelperConf      `yaml:"helper"`
+	Leds           []LedRule       `yaml:"leds"`
+	Devices        []DeviceConf    `yaml:"devices"`
+	Marquee        MarqueeConf     `yaml:"marquee"`
+	Carousel       CarouselConf    `yaml:"carousel"`
+	Buttons        ButtonConf      `yaml:"buttons"`
+	Recording      string          `yaml:"recording"`
+	Snapshot       bool            `yaml:"snapshot"`
+}
+
+// ButtonConf binds soft button gestures to actions
+type ButtonConf struct {
+	LongPressMS   int               `yaml:"longpressms"`
+	DoubleClickMS int               `yaml:"doubleclickms"`
+	Bindings      map[string]string `yaml:"bindings"`
+}
+
+// MarqueeConf configures the horizontal scrolling of lines too long for the MFD
+type MarqueeConf struct {
+	Enabled bool     `yaml:"enabled"`
+	StepMS  int      `yaml:"stepms"`
+	PauseMS int      `yaml:"pausems"`
+	Pages   []string `yaml:"pages"`
+}
+
+// CarouselConf configures rotating through the pages on their own
+type CarouselConf struct {
+	Enabled bool     `yaml:"enabled"`
+	DwellMS int      `yaml:"dwellms"`
+	PauseMS int      `yaml:"pausems"`
+	Pages   []string `yaml:"pages"`
+}
+
+// DeviceConf assigns a set of pages to a single device
+type DeviceConf struct {
+	// ID is the serial number or handle of the device, as shown in the log when the device is found
+	ID    string   `yaml:"id"`
+	Pages []string `yaml:"pages"`
+}
+
+// LedRule lights an X52 Pro LED while a game state condition holds
+type LedRule struct {
+	When  string `yaml:"when"`
+	Led   string `yaml:"led"`
+	Color string `yaml:"color"`
+	Blink bool   `yaml:"blink"`
+}
+
+// WebConf configures the browser based virtual MFD
+type WebConf struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"`
+}
+
+// SerialConf configures a character LCD on a serial port, used instead of the X52 Pro
+type SerialConf struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    string `yaml:"port"`
+	Baud    int    `yaml:"baud"`
+	Width   int    `yaml:"width"`
+	Lines   int    `yaml:"lines"`
+}
+
+// HelperConf configures an external display helper program, used instead of the X52 Pro
+type HelperConf struct {
+	Enabled bool     `yaml:"enabled"`
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+// DevicePages returns the pages assigned to each configured device, keyed by device ID
+func (c Conf) DevicePages() map[string][]string {
+	pages := map[string][]string{}
+	for _, d := range c.Devices {
+		pages[d.ID] = d.Pages
+	}
+	return pages
 }
 
 // LoadConf loads the config from the yaml file
@@ -35,9 +112,3 @@ func LoadConf() Conf {
 
 	return conf
 }
-
-// ExpandJournalFolderPath expands any env variables in the journal folder path.
-func (c Conf) ExpandJournalFolderPath() string {
-	exp, _ := registry.ExpandString(c.JournalsFolder)
-	return exp
-}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package conf
+
+import (
+	"os"
+	"regexp"
+)
+
+var windowsEnvVar = regexp.MustCompile(`%(\w+)%`)
+
+// ExpandJournalFolderPath expands any env variables in the journal folder path.
+// Both the Windows %VAR% and the Unix $VAR forms are supported, so that a journal
+// folder inside a Wine/Proton prefix can be configured.
+func (c Conf) ExpandJournalFolderPath() string {
+	exp := windowsEnvVar.ReplaceAllStringFunc(c.JournalsFolder, func(v string) string {
+		return os.Getenv(v[1 : len(v)-1])
+	})
+	return os.ExpandEnv(exp)
+}
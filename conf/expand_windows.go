@@ -0,0 +1,9 @@
+package conf
+
+import "golang.org/x/sys/windows/registry"
+
+// ExpandJournalFolderPath expands any env variables in the journal folder path.
+func (c Conf) ExpandJournalFolderPath() string {
+	exp, _ := registry.ExpandString(c.JournalsFolder)
+	return exp
+}
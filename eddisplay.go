@@ -146,20 +146,25 @@ func onReady() {
 
 		conf := conf.LoadConf()
 
-		// Calculate number of enabled pages
-		pageCount := 0
-		for _, enabled := range conf.Pages {
-			if enabled {
-				pageCount++
+		mfd.AssignPages(conf.DevicePages())
+		var backend mfd.Backend = mfd.NewDirectOutput()
+		if conf.Serial.Enabled {
+			backend, err = mfd.OpenSerial(conf.Serial.Port, conf.Serial.Baud, mfd.Geometry{Width: conf.Serial.Width, Lines: conf.Serial.Lines})
+			if err != nil {
+				log.Panic(err)
 			}
+		} else if conf.Helper.Enabled {
+			backend = mfd.NewProcess(conf.Helper.Command, conf.Helper.Args...)
 		}
-
-		err = mfd.InitDevice(uint32(pageCount), edsm.ClearCache)
+		err = mfd.InitDevice(backend, edreader.EnabledPages(conf), edsm.ClearCache)
 		if err != nil {
 			log.Panic(err)
 		}
 		defer mfd.DeInitDevice()
 
+		stopDisplay := edreader.StartDisplay(conf)
+		defer stopDisplay()
+
 		edreader.Start(conf)
 		defer edreader.Stop()
 
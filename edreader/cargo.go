@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"os"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/pellux-network/EDx52display/mfd"
 )
 
 const FileCargo = "Cargo.json"
@@ -31,33 +34,30 @@ type CargoLine struct {
 
 func (cl CargoLine) displayname() string {
 	name := cl.Name
+	namesOnce.Do(initNameMap)
 	displayName, ok := names[strings.ToLower(name)]
 	if ok {
 		name = displayName
 	}
-	return name
+	// The name is shown the same on every display, including the titles of the detail pages
+	return mfd.Transliterate(name)
 }
 
 var (
-	names        map[string]string
-	currentCargo Cargo
+	names     map[string]string
+	namesOnce sync.Once
 )
 
-func init() {
-	log.Debugln("Initializing cargo name map...")
-	initNameMap()
-}
-
-func handleCargoFile(file string) {
+func (r *Reader) handleCargoFile(file string) {
 	data, err := os.ReadFile(file)
 	if err != nil {
 		log.Debugln("No cargo file found:", file)
-		currentCargo = Cargo{}
+		r.state.Cargo = Cargo{}
 		return
 	}
 	var cargo Cargo
 	json.Unmarshal(data, &cargo)
-	currentCargo = cargo
+	r.state.Cargo = cargo
 }
 
 func mapCommodities(data [][]string, symbolIdx, nameIdx int) {
@@ -70,6 +70,7 @@ func mapCommodities(data [][]string, symbolIdx, nameIdx int) {
 }
 
 func initNameMap() {
+	log.Debugln("Initializing cargo name map...")
 	commodity := readCsvFile(commodityNameFile)
 	rareCommodity := readCsvFile(rareCommodityNameFile)
 
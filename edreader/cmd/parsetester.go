@@ -25,5 +25,6 @@ func main() {
 		edreader.ParseJournalLine(line, &state)
 		fmt.Printf("%#v\n", state)
 	}
+	fmt.Printf("%+v\n", edreader.JournalEventStats())
 
 }
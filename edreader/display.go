@@ -0,0 +1,78 @@
+package edreader
+
+import (
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pellux-network/EDx52display/conf"
+	"github.com/pellux-network/EDx52display/edsm"
+	"github.com/pellux-network/EDx52display/mfd"
+)
+
+// StartDisplay applies the display settings of the config: the soft button gestures, the marquee and the
+// carousel, and starts the snapshot file, the recording and the virtual MFD web server when they are enabled.
+// The returned function stops them again.
+func StartDisplay(cfg conf.Conf) (stop func()) {
+	var stops []func()
+
+	mfd.RegisterAction("clearcache", edsm.ClearCache)
+	err := mfd.SetGestures(mfd.Gestures{
+		LongPress:   time.Duration(cfg.Buttons.LongPressMS) * time.Millisecond,
+		DoubleClick: time.Duration(cfg.Buttons.DoubleClickMS) * time.Millisecond,
+		Bindings:    cfg.Buttons.Bindings,
+	})
+	if err != nil {
+		log.Warnln("Invalid soft button bindings:", err)
+	}
+
+	if cfg.Marquee.Enabled {
+		mfd.SetMarquee(mfd.Marquee{
+			Step:  time.Duration(cfg.Marquee.StepMS) * time.Millisecond,
+			Pause: time.Duration(cfg.Marquee.PauseMS) * time.Millisecond,
+			Pages: cfg.Marquee.Pages,
+		})
+	}
+
+	if cfg.Carousel.Enabled {
+		mfd.SetCarousel(mfd.Carousel{
+			Dwell: time.Duration(cfg.Carousel.DwellMS) * time.Millisecond,
+			Pause: time.Duration(cfg.Carousel.PauseMS) * time.Millisecond,
+			Pages: cfg.Carousel.Pages,
+		})
+	}
+
+	if cfg.Snapshot {
+		snapshots := mfd.StartSnapshots(mfd.Filename)
+		stops = append(stops, snapshots.Close)
+	}
+
+	if cfg.Recording != "" {
+		recording, err := os.Create(cfg.Recording)
+		if err != nil {
+			log.Warnln("Unable to record the display:", err)
+		} else {
+			mfd.StartRecording(recording)
+			stops = append(stops, func() {
+				mfd.StopRecording()
+				recording.Close()
+			})
+		}
+	}
+
+	if cfg.Web.Enabled {
+		webServer, err := mfd.StartWebServer(cfg.Web.Address)
+		if err != nil {
+			log.Warnln("Unable to start virtual MFD web server:", err)
+		} else {
+			stops = append(stops, func() { webServer.Close() })
+		}
+	}
+
+	return func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package edreader
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -28,7 +29,9 @@ const (
 type PageDef struct {
 	Key         PageKey
 	DisplayName string
-	Render      func(*mfd.Page, Journalstate)
+	Render      func(*mfd.Page, Journalstate, mfd.Geometry)
+	// Visible reports whether the page is shown for the current game state. Pages without it are always shown.
+	Visible func(Journalstate) bool
 }
 
 // Registry of all possible pages
@@ -50,95 +53,192 @@ var PageRegistry = []PageDef{
 	},
 }
 
-// Mfd is the MFD display structure to be used by this module.
-var (
-	Mfd     mfd.Display
-	MfdLock = sync.RWMutex{}
-	PrevMfd mfd.Display
-	watcher *fsnotify.Watcher
-	stopCh  chan struct{}
-)
+// Reader follows the journal folder of the game and renders the pages for the MFD from it.
+// It owns all the state read from the journal, so several readers can run side by side.
+type Reader struct {
+	cfg    conf.Conf
+	folder string
+
+	// The state read so far, and how far the journal and status files have been read
+	state         Journalstate
+	journalFile   string
+	journalOffset int64
+	statusSize    int64
+	statusModTime time.Time
+
+	firstEnabledPage string
+	ledRules         []ledRule
+	// Whether the arrival screen was posted for the current arrival
+	arrivalPosted bool
+
+	// The pages last rendered and written to the MFD
+	mu          sync.RWMutex
+	display     mfd.Display
+	prevDisplay mfd.Display
+
+	watcher      *fsnotify.Watcher
+	removeLayout func()
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+// The reader used by Start and Stop
+var defaultReader *Reader
+
+// NewReader returns a reader for the journal folder and pages in the config
+func NewReader(cfg conf.Conf) *Reader {
+	r := &Reader{
+		cfg:              cfg,
+		folder:           cfg.ExpandJournalFolderPath(),
+		firstEnabledPage: firstEnabledPage(cfg.Pages),
+		ledRules:         loadLedRules(cfg.Leds),
+	}
+	r.state.ShowSplashScreen = true
+	r.state.SplashScreenStartTime = time.Now()
+	return r
+}
 
 // Start starts the Elite Dangerous journal reader routine using fsnotify
 func Start(cfg conf.Conf) {
+	defaultReader = NewReader(cfg)
+	if err := defaultReader.Start(); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Stop closes the watcher again
+func Stop() {
+	if defaultReader != nil {
+		defaultReader.Stop()
+	}
+}
+
+// Start shows the splash screen, renders the pages and keeps them up to date as the game writes to the journal folder
+func (r *Reader) Start() error {
 	log.Info("Starting journal listener")
-	journalfolder := cfg.ExpandJournalFolderPath()
-	log.Debugln("Looking for journal files in " + journalfolder)
+	log.Debugln("Looking for journal files in " + r.folder)
 
-	// Set the first enabled page key for splash logic
-	SetFirstEnabledPageKey(cfg.Pages)
+	// The name files are read up front, so a broken installation fails straight away
+	namesOnce.Do(initNameMap)
 
-	updateMFD(journalfolder, cfg)
+	r.postSplashScreen()
+	r.Refresh()
 
-	var err error
-	watcher, err = fsnotify.NewWatcher()
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Panicf("Failed to create file watcher: %v", err)
+		return fmt.Errorf("failed to create file watcher: %w", err)
 	}
-	stopCh = make(chan struct{})
-
 	// Watch the folder for new/changed files
-	err = watcher.Add(journalfolder)
-	if err != nil {
-		log.Panicf("Failed to add watcher: %v", err)
+	if err := watcher.Add(r.folder); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to add watcher: %w", err)
 	}
+	r.watcher = watcher
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	// Lay the pages out again when a display of another size is plugged in
+	relayout := make(chan struct{}, 1)
+	r.removeLayout = mfd.AddLayoutListener(func(mfd.Geometry) {
+		select {
+		case relayout <- struct{}{}:
+		default:
+		}
+	})
 
 	// Prefetch stations for the initial system (if known)
-	if lastJournalState.Location.SystemAddress != 0 {
-		PrefetchStations(lastJournalState.Location.SystemAddress)
+	if r.state.Location.SystemAddress != 0 {
+		PrefetchStations(r.state.Location.SystemAddress)
 	}
 
-	go func() {
-		defer watcher.Close()
-		for {
-			select {
-			case event := <-watcher.Events:
-				// Only react to writes/creates/renames
-				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
-					updateMFD(journalfolder, cfg)
-				}
-			case err := <-watcher.Errors:
-				log.Warnf("Watcher error: %v", err)
-			case <-stopCh:
-				return
+	go r.watch(relayout)
+	return nil
+}
+
+// Stop stops following the journal folder
+func (r *Reader) Stop() {
+	log.Debugf("Journal lines parsed: %+v", JournalEventStats())
+	if r.stop == nil {
+		return
+	}
+	r.removeLayout()
+	close(r.stop)
+	<-r.done
+	r.stop = nil
+}
+
+func (r *Reader) watch(relayout <-chan struct{}) {
+	defer close(r.done)
+	defer r.watcher.Close()
+	for {
+		select {
+		case event := <-r.watcher.Events:
+			// Only react to writes/creates/renames
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				r.Refresh()
 			}
+		case <-relayout:
+			r.Refresh()
+		case err := <-r.watcher.Errors:
+			log.Warnf("Watcher error: %v", err)
+		case <-r.stop:
+			return
 		}
-	}()
+	}
 }
 
-func updateMFD(journalfolder string, cfg conf.Conf) {
-	journalFile := findJournalFile(journalfolder)
-	handleJournalFile(journalFile)
-	handleStatusFile(filepath.Join(journalfolder, "Status.json"))
-	handleModulesInfoFile(filepath.Join(journalfolder, FileModulesInfo))
+// State returns the game state read so far. Must not be called while the reader is running.
+func (r *Reader) State() Journalstate {
+	return r.state
+}
+
+// Display returns the pages last rendered
+func (r *Reader) Display() mfd.Display {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.display.Copy()
+}
+
+// EnabledPages returns the keys of the pages enabled in the config, in display order
+func EnabledPages(cfg conf.Conf) []string {
+	pages := []string{}
+	for _, pageDef := range PageRegistry {
+		if cfg.Pages[string(pageDef.Key)] {
+			pages = append(pages, string(pageDef.Key))
+		}
+	}
+	return pages
+}
+
+// Refresh reads what changed in the journal folder and renders the pages again
+func (r *Reader) Refresh() {
+	journalFile := findJournalFile(r.folder)
+	r.handleJournalFile(journalFile)
+	r.handleStatusFile(filepath.Join(r.folder, "Status.json"))
+	r.handleModulesInfoFile(filepath.Join(r.folder, FileModulesInfo))
 
 	// Update in-memory cargo before rendering pages
-	handleCargoFile(filepath.Join(journalfolder, FileCargo))
+	r.handleCargoFile(filepath.Join(r.folder, FileCargo))
+
+	updateLeds(r.ledRules, r.state)
+	r.updateOverlays(r.state)
 
-	// Build enabled pages
+	// Build enabled pages, laid out for the displays attached
+	g := mfd.LayoutGeometry()
 	var enabledPages []mfd.Page
 	for _, pageDef := range PageRegistry {
-		if cfg.Pages[string(pageDef.Key)] {
+		if r.cfg.Pages[string(pageDef.Key)] && (pageDef.Visible == nil || pageDef.Visible(r.state)) {
 			page := mfd.NewPage()
-			pageDef.Render(&page, lastJournalState)
+			page.Key = string(pageDef.Key)
+			pageDef.Render(&page, r.state, g)
 			enabledPages = append(enabledPages, page)
 		}
 	}
-	MfdLock.Lock()
-	Mfd = mfd.Display{Pages: enabledPages}
-	MfdLock.Unlock()
-
-	swapMfd()
-}
+	r.mu.Lock()
+	r.display = mfd.Display{Pages: enabledPages}
+	r.mu.Unlock()
 
-// Stop closes the watcher again
-func Stop() {
-	if stopCh != nil {
-		close(stopCh)
-	}
-	if watcher != nil {
-		watcher.Close()
-	}
+	r.swapMfd()
 }
 
 func findJournalFile(folder string) string {
@@ -162,13 +262,15 @@ func findJournalFile(folder string) string {
 	return mostRecentJournal
 }
 
-func swapMfd() {
-	MfdLock.RLock()
-	defer MfdLock.RUnlock()
-	eq := cmp.Equal(Mfd, PrevMfd)
+func (r *Reader) swapMfd() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	eq := cmp.Equal(r.display, r.prevDisplay)
 	if !eq {
-		mfd.Write(Mfd)
-		PrevMfd = Mfd.Copy()
+		if err := mfd.Write(r.display); err != nil {
+			log.Warnln("Unable to update the display:", err)
+		}
+		r.prevDisplay = r.display.Copy()
 	}
 }
 
@@ -0,0 +1,101 @@
+package edreader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pellux-network/EDx52display/conf"
+)
+
+// journalFolder writes a journal folder with the given journal lines and cargo file
+func journalFolder(t *testing.T, cargo string, lines ...string) string {
+	dir := t.TempDir()
+	journal := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "Journal.2025-07-12T100000.01.log"), []byte(journal), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileCargo), []byte(cargo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestReadersKeepTheirOwnState(t *testing.T) {
+	resetEventStats(t)
+	for _, tt := range []struct {
+		name     string
+		target   string
+		capacity string
+	}{
+		{"sol", "Sol", "64"},
+		{"achenar", "Achenar", "128"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := journalFolder(t, `{"Count":0,"Inventory":[]}`,
+				`{ "timestamp":"2025-07-12T10:00:00Z", "event":"Loadout", "CargoCapacity":`+tt.capacity+` }`,
+				`{ "timestamp":"2025-07-12T10:00:01Z", "event":"FSDTarget", "Name":"`+tt.target+`", "SystemAddress":1 }`)
+			r := NewReader(conf.Conf{JournalsFolder: dir, Pages: map[string]bool{"cargo": true}})
+			r.Refresh()
+
+			if got := r.State().EDSMTarget.Name; got != tt.target {
+				t.Errorf("got target %q, wanted %q", got, tt.target)
+			}
+			display := r.Display()
+			if len(display.Pages) != 1 {
+				t.Fatalf("got %d pages, wanted the cargo page", len(display.Pages))
+			}
+			if header := display.Pages[0].Lines[0]; !strings.HasSuffix(header, fmt.Sprintf("0000/%04s", tt.capacity)) {
+				t.Errorf("got cargo header %q, wanted a capacity of %s", header, tt.capacity)
+			}
+		})
+	}
+}
+
+func TestReaderReadsNewLinesOnly(t *testing.T) {
+	resetEventStats(t)
+	dir := journalFolder(t, `{"Count":0,"Inventory":[]}`,
+		`{ "timestamp":"2025-07-12T10:00:00Z", "event":"FSDTarget", "Name":"Sol", "SystemAddress":1 }`)
+	r := NewReader(conf.Conf{JournalsFolder: dir})
+	r.Refresh()
+
+	f, err := os.OpenFile(filepath.Join(dir, "Journal.2025-07-12T100000.01.log"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{ "timestamp":"2025-07-12T10:00:01Z", "event":"NavRouteClear" }` + "\n")
+	f.Close()
+	r.Refresh()
+	if got := r.State().EDSMTarget; got != (EDSMTarget{}) {
+		t.Errorf("got target %+v after the route was cleared", got)
+	}
+	if got := r.State().LastFSDTargetSystem; got != "" {
+		t.Errorf("the target %q was read again", got)
+	}
+}
+
+func TestReaderWaitsForCompleteLines(t *testing.T) {
+	resetEventStats(t)
+	dir := journalFolder(t, `{"Count":0,"Inventory":[]}`)
+	path := filepath.Join(dir, "Journal.2025-07-12T100000.01.log")
+	r := NewReader(conf.Conf{JournalsFolder: dir})
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString(`{ "timestamp":"2025-07-12T10:00:00Z", "event":"FSDTarget", "Na`)
+	r.Refresh()
+	if got := JournalEventStats().Malformed; got != 0 {
+		t.Errorf("half a line was parsed as %d malformed lines", got)
+	}
+	f.WriteString(`me":"Sol", "SystemAddress":1 }` + "\n")
+	r.Refresh()
+	if got := r.State().EDSMTarget.Name; got != "Sol" {
+		t.Errorf("got target %q once the line was complete, wanted Sol", got)
+	}
+}
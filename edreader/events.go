@@ -0,0 +1,216 @@
+package edreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/buger/jsonparser"
+)
+
+// LocationEvent is the part of the Location, FSDJump and SupercruiseExit events that tells where the player is
+type LocationEvent struct {
+	StarSystem    string
+	SystemAddress int64
+	Body          string
+	BodyID        int64
+	BodyType      string
+	// Latitude is only present on the surface of a planet
+	Latitude  *float64
+	Longitude float64
+	Docked    bool
+}
+
+// SupercruiseEntryEvent is written when the player enters supercruise
+type SupercruiseEntryEvent struct {
+	StarSystem    string
+	SystemAddress int64
+}
+
+// TouchdownEvent is written when the ship lands on a planet
+type TouchdownEvent struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// LiftoffEvent is written when the ship takes off from a planet
+type LiftoffEvent struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// FSDTargetEvent is written when a system is targeted for a jump
+type FSDTargetEvent struct {
+	Name          string
+	SystemAddress int64
+	// RemainingJumpsInRoute is only present while a route is plotted
+	RemainingJumpsInRoute *int
+}
+
+// ApproachBodyEvent is written when the ship gets close to a planet
+type ApproachBodyEvent struct {
+	Body   string
+	BodyID int64
+}
+
+// ApproachSettlementEvent is written when the ship gets close to a settlement on a planet
+type ApproachSettlementEvent struct {
+	Name     string
+	BodyName string
+	BodyID   int64
+}
+
+// LoadoutEvent describes the ship of the player
+type LoadoutEvent struct {
+	// CargoCapacity is missing from some older journals
+	CargoCapacity *int
+}
+
+// NavRouteClearEvent is written when the plotted route is cleared
+type NavRouteClearEvent struct{}
+
+// ReceiveTextEvent is a message received by the player
+type ReceiveTextEvent struct {
+	From    string
+	Message string
+	Channel string
+}
+
+// DockedEvent is written when the ship docks at a station
+type DockedEvent struct {
+	StationName   string
+	StationType   string
+	StarSystem    string
+	SystemAddress int64
+}
+
+func init() {
+	RegisterEvent("Location", eLocation)
+	RegisterEvent("SupercruiseEntry", eSupercruiseEntry)
+	RegisterEvent("SupercruiseExit", eSupercruiseExit)
+	RegisterEvent("FSDJump", eFSDJump)
+	RegisterEvent("Touchdown", eTouchDown)
+	RegisterEvent("Liftoff", eLiftoff)
+	RegisterEvent("FSDTarget", eFSDTarget)
+	RegisterEvent("ApproachBody", eApproachBody)
+	RegisterEvent("ApproachSettlement", eApproachSettlement)
+	RegisterEvent("Loadout", eLoadout)
+	RegisterEvent("NavRouteClear", eNavRouteClear)
+	RegisterEvent("ReceiveText", eReceiveText)
+	RegisterEvent("Docked", eDocked)
+}
+
+// eventHandler decodes a journal line into the event type of a handler and calls it
+type eventHandler func(line []byte, state *Journalstate) error
+
+// EventStats counts the journal lines parsed so far
+type EventStats struct {
+	// Handled is the number of lines of each event with a registered handler
+	Handled map[string]int
+	// Unknown is the number of lines of each event without a handler
+	Unknown map[string]int
+	// Malformed is the number of lines without an event name, or that couldn't be decoded
+	Malformed int
+}
+
+var (
+	eventsMu      sync.RWMutex
+	eventHandlers = map[string][]eventHandler{}
+
+	statsMu    sync.Mutex
+	eventStats = newEventStats()
+)
+
+func newEventStats() EventStats {
+	return EventStats{Handled: map[string]int{}, Unknown: map[string]int{}}
+}
+
+// RegisterEvent registers a handler for a journal event. Each line of the event is decoded into a new T,
+// using the journal field names as the JSON keys, and passed to the handler with the state to update.
+// Several handlers can be registered for an event; they run in the order they were registered.
+func RegisterEvent[T any](event string, handler func(e *T, state *Journalstate)) {
+	eventsMu.Lock()
+	defer eventsMu.Unlock()
+	eventHandlers[event] = append(eventHandlers[event], func(line []byte, state *Journalstate) error {
+		e := new(T)
+		if err := json.Unmarshal(line, e); err != nil {
+			return err
+		}
+		handler(e, state)
+		return nil
+	})
+}
+
+// JournalEventStats returns the number of journal lines parsed so far, by event
+func JournalEventStats() EventStats {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stats := newEventStats()
+	for event, n := range eventStats.Handled {
+		stats.Handled[event] = n
+	}
+	for event, n := range eventStats.Unknown {
+		stats.Unknown[event] = n
+	}
+	stats.Malformed = eventStats.Malformed
+	return stats
+}
+
+// ParseJournalLine parses a single line of the journal and updates the state with it.
+func ParseJournalLine(line []byte, state *Journalstate) {
+	event, ok := eventName(line)
+	if !ok {
+		malformedLine(line, "no event name")
+		return
+	}
+	eventsMu.RLock()
+	handlers := eventHandlers[event]
+	eventsMu.RUnlock()
+	if len(handlers) == 0 {
+		statsMu.Lock()
+		eventStats.Unknown[event]++
+		statsMu.Unlock()
+		return
+	}
+	for _, handle := range handlers {
+		if err := handle(line, state); err != nil {
+			malformedLine(line, err.Error())
+			return
+		}
+	}
+	statsMu.Lock()
+	eventStats.Handled[event]++
+	statsMu.Unlock()
+}
+
+// malformedLine counts and logs a journal line that couldn't be parsed
+func malformedLine(line []byte, reason string) {
+	statsMu.Lock()
+	eventStats.Malformed++
+	statsMu.Unlock()
+	const maxLogged = 200
+	if len(line) > maxLogged {
+		line = line[:maxLogged]
+	}
+	log.Warnf("Malformed journal line (%s): %s", reason, line)
+}
+
+var eventKey = []byte(`"event":"`)
+
+// eventName returns the name of the event of a journal line. The game writes the event right after
+// the timestamp without any spaces, so it is found without parsing the line; other lines are parsed.
+func eventName(line []byte) (string, bool) {
+	if i := bytes.Index(line, eventKey); i >= 0 {
+		rest := line[i+len(eventKey):]
+		if end := bytes.IndexByte(rest, '"'); end > 0 && bytes.IndexByte(rest[:end], '\\') < 0 {
+			return string(rest[:end]), true
+		}
+	}
+	event, err := jsonparser.GetString(line, "event")
+	if err != nil || event == "" {
+		return "", false
+	}
+	return event, true
+}
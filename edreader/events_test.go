@@ -0,0 +1,129 @@
+package edreader
+
+import (
+	"maps"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+const fsdTargetLine = `{ "timestamp":"2025-07-12T10:00:00Z", "event":"FSDTarget", "Name":"Sol", "SystemAddress":10477373803, "StarClass":"G", "RemainingJumpsInRoute":3 }`
+
+// resetEventStats counts the journal lines of a test from zero and restores the counts when the test ends
+func resetEventStats(t *testing.T) {
+	statsMu.Lock()
+	saved := eventStats
+	eventStats = newEventStats()
+	statsMu.Unlock()
+	t.Cleanup(func() {
+		statsMu.Lock()
+		eventStats = saved
+		statsMu.Unlock()
+	})
+}
+
+// keepEventHandlers restores the registered event handlers when the test ends
+func keepEventHandlers(t *testing.T) {
+	eventsMu.RLock()
+	saved := maps.Clone(eventHandlers)
+	for event, handlers := range saved {
+		saved[event] = slices.Clone(handlers)
+	}
+	eventsMu.RUnlock()
+	t.Cleanup(func() {
+		eventsMu.Lock()
+		eventHandlers = saved
+		eventsMu.Unlock()
+	})
+}
+
+func TestEventName(t *testing.T) {
+	for _, tt := range []struct {
+		line  string
+		event string
+		ok    bool
+	}{
+		{fsdTargetLine, "FSDTarget", true},
+		{`{"event": "Liftoff", "timestamp": "2025-07-12T10:00:00Z"}`, "Liftoff", true},
+		{`{"timestamp":"2025-07-12T10:00:00Z","event":"Odd\"Name"}`, `Odd"Name`, true},
+		{`{"timestamp":"2025-07-12T10:00:00Z"}`, "", false},
+		{`{"timestamp":"2025-07-12T10:00:00Z", "event":"FSDTar`, "", false},
+		{``, "", false},
+	} {
+		event, ok := eventName([]byte(tt.line))
+		if event != tt.event || ok != tt.ok {
+			t.Errorf("eventName(%s) = %q, %v, wanted %q, %v", tt.line, event, ok, tt.event, tt.ok)
+		}
+	}
+}
+
+func TestParseJournalLine(t *testing.T) {
+	resetEventStats(t)
+	var state Journalstate
+	ParseJournalLine([]byte(fsdTargetLine), &state)
+	if state.EDSMTarget.Name != "Sol" || state.EDSMTarget.SystemAddress != 10477373803 || state.EDSMTarget.RemainingJumpsInRoute != 3 {
+		t.Errorf("got target %+v", state.EDSMTarget)
+	}
+	ParseJournalLine([]byte(`{ "timestamp":"2025-07-12T10:00:00Z", "event":"NavRouteClear" }`), &state)
+	if state.EDSMTarget != (EDSMTarget{}) || state.LastFSDTargetSystem != "" {
+		t.Errorf("route wasn't cleared: %+v", state.EDSMTarget)
+	}
+	ParseJournalLine([]byte(`{ "timestamp":"2025-07-12T10:00:00Z", "event":"Music", "MusicTrack":"Exploration" }`), &state)
+	ParseJournalLine([]byte(`{ "timestamp":"2025-07-12T10:00:00Z", "event":"FSDTarget", "Name":42 }`), &state)
+	ParseJournalLine([]byte(`{ "timestamp":"2025-07-12T10:0`), &state)
+
+	stats := JournalEventStats()
+	if stats.Handled["FSDTarget"] != 1 || stats.Handled["NavRouteClear"] != 1 {
+		t.Errorf("got handled %v", stats.Handled)
+	}
+	if stats.Unknown["Music"] != 1 {
+		t.Errorf("got unknown %v", stats.Unknown)
+	}
+	if stats.Malformed != 2 {
+		t.Errorf("got %d malformed lines, wanted 2", stats.Malformed)
+	}
+}
+
+func TestRegisterEvent(t *testing.T) {
+	type musicEvent struct {
+		MusicTrack string
+	}
+	resetEventStats(t)
+	keepEventHandlers(t)
+	var tracks []string
+	RegisterEvent("Music", func(e *musicEvent, _ *Journalstate) {
+		tracks = append(tracks, e.MusicTrack)
+	})
+	var state Journalstate
+	ParseJournalLine([]byte(`{ "timestamp":"2025-07-12T10:00:00Z", "event":"Music", "MusicTrack":"Exploration" }`), &state)
+	if len(tracks) != 1 || tracks[0] != "Exploration" {
+		t.Errorf("got tracks %q", tracks)
+	}
+}
+
+func BenchmarkEventName(b *testing.B) {
+	line := []byte(fsdTargetLine)
+	b.ReportAllocs()
+	for b.Loop() {
+		eventName(line)
+	}
+}
+
+// BenchmarkEventNameRegexp is how the event name used to be found, for comparison
+func BenchmarkEventNameRegexp(b *testing.B) {
+	line := []byte(fsdTargetLine)
+	b.ReportAllocs()
+	for b.Loop() {
+		re := regexp.MustCompile(`"event":"(\w*)"`)
+		re.FindStringSubmatch(string(line))
+	}
+}
+
+func BenchmarkParseJournalLine(b *testing.B) {
+	line := []byte(fsdTargetLine)
+	var state Journalstate
+	b.ReportAllocs()
+	for b.Loop() {
+		ParseJournalLine(line, &state)
+	}
+}
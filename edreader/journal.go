@@ -1,12 +1,10 @@
 package edreader
 
 import (
-	"bufio"
 	"io"
 	"os"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -39,8 +37,15 @@ type Journalstate struct {
 	ArrivedAtFSDTargetTime time.Time
 	LastFSDTargetSystem    string
 	LastFSDTargetAddress   int64
-	ShowSplashScreen       bool      // NEW: splash flag
-	SplashScreenStartTime  time.Time // NEW: splash start time
+	ShowSplashScreen       bool              // NEW: splash flag
+	SplashScreenStartTime  time.Time         // NEW: splash start time
+	StatusFlags            int64             // Flags from Status.json
+	Cargo                  Cargo             // From Cargo.json
+	Modules                ModulesInfo       // From ModulesInfo.json
+	LoadoutCargoCapacity   int               // From the Loadout event
+	FleetCarrierNames      map[string]string // Names of the fleet carriers seen, by ID
+	// The system stations were last prefetched for
+	prefetchedSystem int64
 }
 
 // Location indicates the players current location in the game
@@ -72,79 +77,9 @@ type Destination struct {
 	Name          string
 }
 
-const (
-	systemaddress = "SystemAddress"
-	bodyid        = "BodyID"
-	starsystem    = "StarSystem"
-	docked        = "Docked"
-	body          = "Body"
-	bodytype      = "BodyType"
-	bodyname      = "BodyName"
-	stationname   = "StationName"
-	stationtype   = "StationType"
-	latitude      = "Latitude"
-	longitude     = "Longitude"
-	name          = "Name"
-)
-
-// --- Fleet Carrier helpers ---
-
-var (
-	lastFCReceiveTextNameMu sync.Mutex
-	lastFCReceiveTextName   = map[string]string{} // map[fcID]fcName
-)
-
-type parser struct {
-	line []byte
-}
-
-func (p *parser) getString(field string) (string, bool) {
-	str, err := jsonparser.GetString(p.line, field)
-	if err != nil {
-		return "", false
-	}
-	return str, true
-}
-
-func (p *parser) getInt(field string) (int64, bool) {
-	num, err := jsonparser.GetInt(p.line, field)
-	if err != nil {
-		return 0, false
-	}
-	return num, true
-}
-
-func (p *parser) getBool(field string) (bool, bool) {
-	b, err := jsonparser.GetBoolean(p.line, field)
-	if err != nil {
-		return false, false
-	}
-	return b, true
-}
-
-func (p *parser) getFloat(field string) (float64, bool) {
-	f, err := jsonparser.GetFloat(p.line, field)
-	if err != nil {
-		return 0, false
-	}
-	return f, true
-}
-
 var printer = message.NewPrinter(language.English)
 
-var (
-	lastJournalFile     string
-	lastJournalOffset   int64
-	lastJournalState    Journalstate
-	lastStatusFileSize  int64  // NEW: for status file change detection
-	firstEnabledPageKey string // NEW: track first enabled page
-	lastSystemAddress   int64  // NEW: track last system address for prefetching
-)
-
-func init() {
-	lastJournalState.ShowSplashScreen = true
-	lastJournalState.SplashScreenStartTime = time.Now()
-}
+// --- Fleet Carrier helpers ---
 
 // ExtractFleetCarrierNameID splits a string like "Stormcrow VZY-8XQ" into ("Stormcrow", "VZY-8XQ").
 // Returns ("", "") if not a FC.
@@ -163,7 +98,7 @@ func ExtractFleetCarrierNameID(full string) (string, string) {
 }
 
 // SaveFleetCarrierReceiveText remembers the last FC name for a given ID.
-func SaveFleetCarrierReceiveText(from string) {
+func (state *Journalstate) SaveFleetCarrierReceiveText(from string) {
 	parts := strings.Fields(from)
 	if len(parts) < 2 {
 		return
@@ -174,30 +109,30 @@ func SaveFleetCarrierReceiveText(from string) {
 	}
 	name := strings.TrimSpace(strings.TrimSuffix(from, id))
 	name = strings.TrimSpace(name)
-	lastFCReceiveTextNameMu.Lock()
-	lastFCReceiveTextName[id] = name
-	lastFCReceiveTextNameMu.Unlock()
+	if state.FleetCarrierNames == nil {
+		state.FleetCarrierNames = map[string]string{}
+	}
+	state.FleetCarrierNames[id] = name
 }
 
 // GetLastFleetCarrierName returns the last seen FC name for a given ID, or "".
-func GetLastFleetCarrierName(id string) string {
-	lastFCReceiveTextNameMu.Lock()
-	defer lastFCReceiveTextNameMu.Unlock()
-	return lastFCReceiveTextName[id]
+func (state Journalstate) GetLastFleetCarrierName(id string) string {
+	return state.FleetCarrierNames[id]
 }
 
-// Call this at startup after loading config, e.g. in main or Start()
-func SetFirstEnabledPageKey(cfg map[string]bool) {
+// firstEnabledPage returns the key of the first of the enabled pages the splash screen waits for
+func firstEnabledPage(pages map[string]bool) string {
 	for _, key := range []string{"destination", "location", "cargo"} {
-		if cfg[key] {
-			firstEnabledPageKey = key
-			break
+		if pages[key] {
+			return key
 		}
 	}
+	return ""
 }
 
 // handleJournalFile reads only new lines from the journal file since the last read.
-func handleJournalFile(filename string) {
+// A line the game is still writing is read once it is complete.
+func (r *Reader) handleJournalFile(filename string) {
 	if filename == "" {
 		return
 	}
@@ -209,8 +144,8 @@ func handleJournalFile(filename string) {
 	defer file.Close()
 
 	var offset int64 = 0
-	if filename == lastJournalFile {
-		offset = lastJournalOffset
+	if filename == r.journalFile {
+		offset = r.journalOffset
 	}
 
 	info, err := file.Stat()
@@ -230,28 +165,28 @@ func handleJournalFile(filename string) {
 		return
 	}
 
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
-	state := lastJournalState // Start from last known state
+	state := r.state // Start from last known state
 	linesRead := 0
-	for scanner.Scan() {
-		ParseJournalLine(scanner.Bytes(), &state)
+	n, err := tailLines(file, func(line []byte) {
+		ParseJournalLine(line, &state)
 		linesRead++
+	})
+	if err != nil {
+		log.Warnln("Error reading journal file ", filename, err)
 	}
 	if linesRead > 0 {
-		lastJournalState = state // Only update if new lines were read
+		r.state = state // Only update if new lines were read
 	}
 
-	// Save offset for next time
-	pos, _ := file.Seek(0, 1)
-	lastJournalFile = filename
-	lastJournalOffset = pos
+	// Save offset for next time, at the end of the last complete line
+	r.journalFile = filename
+	r.journalOffset = offset + n
 
-	checkSplashScreen()
+	r.checkSplashScreen()
 }
 
 // handleStatusFile reads Status.json for the current destination
-func handleStatusFile(filename string) {
+func (r *Reader) handleStatusFile(filename string) {
 	if filename == "" {
 		return
 	}
@@ -265,16 +200,23 @@ func handleStatusFile(filename string) {
 	if err != nil {
 		return
 	}
-	if info.Size() == lastStatusFileSize {
+	// The size alone does not change when only a flag flips
+	if info.Size() == r.statusSize && info.ModTime().Equal(r.statusModTime) {
 		return
 	}
-	lastStatusFileSize = info.Size()
+	r.statusSize = info.Size()
+	r.statusModTime = info.ModTime()
 
 	data, err := io.ReadAll(file)
 	if err != nil {
 		return
 	}
 
+	flags, err := jsonparser.GetInt(data, "Flags")
+	if err == nil {
+		r.state.StatusFlags = flags
+	}
+
 	destObj, _, _, err := jsonparser.Get(data, "Destination")
 	if err == nil && len(destObj) > 0 {
 		sysID, _ := jsonparser.GetInt(destObj, "System")
@@ -295,117 +237,70 @@ func handleStatusFile(filename string) {
 		fcName, fcID := ExtractFleetCarrierNameID(name)
 		if fcID != "" {
 			// Store for session (for TGT FC page)
-			lastFCReceiveTextNameMu.Lock()
-			if _, ok := lastFCReceiveTextName[fcID]; !ok {
-				lastFCReceiveTextName[fcID] = fcName
+			if _, ok := r.state.FleetCarrierNames[fcID]; !ok {
+				if r.state.FleetCarrierNames == nil {
+					r.state.FleetCarrierNames = map[string]string{}
+				}
+				r.state.FleetCarrierNames[fcID] = fcName
 			}
-			lastFCReceiveTextNameMu.Unlock()
 		}
-		lastJournalState.Destination = Destination{
+		r.state.Destination = Destination{
 			SystemAddress: sysID,
 			BodyID:        bodyID,
 			Name:          name,
 		}
 	} else {
-		lastJournalState.Destination = Destination{}
+		r.state.Destination = Destination{}
 	}
 
 	// After updating Destination, check for arrival
-	checkArrival()
+	checkArrival(&r.state)
 
-	checkSplashScreen()
+	r.checkSplashScreen()
 }
 
-// ParseJournalLine parses a single line of the journal and returns the new state after parsing.
-func ParseJournalLine(line []byte, state *Journalstate) {
-	re := regexp.MustCompile(`"event":"(\w*)"`)
-
-	event := re.FindStringSubmatch(string(line))
-	if len(event) < 2 {
-		// Not a valid event line, skip
-		return
-	}
-	p := parser{line}
-	switch event[1] {
-	case "Location":
-		eLocation(p, state)
-	case "SupercruiseEntry":
-		eSupercruiseEntry(p, state)
-	case "SupercruiseExit":
-		eSupercruiseExit(p, state)
-	case "FSDJump":
-		eFSDJump(p, state)
-	case "Touchdown":
-		eTouchDown(p, state)
-	case "Liftoff":
-		eLiftoff(p, state)
-	case "FSDTarget":
-		eFSDTarget(p, state)
-	case "ApproachBody":
-		eApproachBody(p, state)
-	case "ApproachSettlement":
-		eApproachSettlement(p, state)
-	case "Loadout":
-		eLoadout(p)
-	case "NavRouteClear":
-		state.EDSMTarget = EDSMTarget{}
-		state.LastFSDTargetSystem = ""
-		state.LastFSDTargetAddress = 0
-		state.ArrivedAtFSDTarget = false
-		state.ArrivedAtFSDTargetTime = time.Time{}
-	case "ReceiveText":
-		eReceiveText(p)
-	case "Docked":
-		eDocked(p, state)
-	}
-}
-
-func eLocation(p parser, state *Journalstate) {
+func eLocation(e *LocationEvent, state *Journalstate) {
 	// clear current location completely
 	state.Type = LocationSystem
-	state.Location.SystemAddress, _ = p.getInt(systemaddress)
-	state.StarSystem, _ = p.getString(starsystem)
+	state.Location.SystemAddress = e.SystemAddress
+	state.StarSystem = e.StarSystem
 
 	// Prefetch stations if system changed
-	if state.Location.SystemAddress != lastSystemAddress && state.Location.SystemAddress != 0 {
+	if state.Location.SystemAddress != state.prefetchedSystem && state.Location.SystemAddress != 0 {
 		PrefetchStations(state.Location.SystemAddress)
-		lastSystemAddress = state.Location.SystemAddress
+		state.prefetchedSystem = state.Location.SystemAddress
 	}
 
-	bodyType, ok := p.getString(bodytype)
-
-	if ok && bodyType == "Planet" {
-		state.Location.BodyID, _ = p.getInt(bodyid)
-		state.Location.Body, _ = p.getString(body)
-		state.BodyType, _ = p.getString(bodytype)
+	if e.BodyType == "Planet" {
+		state.Location.BodyID = e.BodyID
+		state.Location.Body = e.Body
+		state.BodyType = e.BodyType
 		state.Type = LocationPlanet
 
-		lat, ok := p.getFloat(latitude)
-		if ok {
-			state.Latitude = lat
-			state.Longitude, _ = p.getFloat(longitude)
+		if e.Latitude != nil {
+			state.Latitude = *e.Latitude
+			state.Longitude = e.Longitude
 			state.Type = LocationLanded
 		}
 	}
 
-	docked, _ := p.getBool(docked)
-	if docked {
+	if e.Docked {
 		state.Type = LocationDocked
 	}
 }
 
-func eSupercruiseEntry(p parser, state *Journalstate) {
+func eSupercruiseEntry(e *SupercruiseEntryEvent, state *Journalstate) {
 	state.Type = LocationSystem // don't throw away info
 }
 
-func eSupercruiseExit(p parser, state *Journalstate) {
-	eLocation(p, state)
+func eSupercruiseExit(e *LocationEvent, state *Journalstate) {
+	eLocation(e, state)
 }
 
-func eFSDJump(p parser, state *Journalstate) {
-	eLocation(p, state)
-	jumpSystem, _ := p.getString(starsystem)
-	jumpAddress, _ := p.getInt(systemaddress)
+func eFSDJump(e *LocationEvent, state *Journalstate) {
+	eLocation(e, state)
+	jumpSystem := e.StarSystem
+	jumpAddress := e.SystemAddress
 	// Only trigger arrival if there was a valid FSD target (not zero/empty)
 	if (state.LastFSDTargetAddress != 0 && jumpAddress == state.LastFSDTargetAddress) ||
 		(state.LastFSDTargetSystem != "" && jumpSystem != "" && strings.EqualFold(jumpSystem, state.LastFSDTargetSystem)) {
@@ -425,23 +320,21 @@ func eFSDJump(p parser, state *Journalstate) {
 	}
 }
 
-func eTouchDown(p parser, state *Journalstate) {
-	state.Latitude, _ = p.getFloat(latitude)
-	state.Longitude, _ = p.getFloat(longitude)
+func eTouchDown(e *TouchdownEvent, state *Journalstate) {
+	state.Latitude = e.Latitude
+	state.Longitude = e.Longitude
 	state.Type = LocationLanded
 }
 
-func eLiftoff(p parser, state *Journalstate) {
+func eLiftoff(e *LiftoffEvent, state *Journalstate) {
 	state.Type = LocationPlanet
 }
 
-func eFSDTarget(p parser, state *Journalstate) {
-	systemAddress, _ := p.getInt(systemaddress)
-	state.EDSMTarget.SystemAddress = systemAddress
-	state.EDSMTarget.Name, _ = p.getString(name)
-	jumps, ok := p.getInt("RemainingJumpsInRoute")
-	if ok && systemAddress != 0 {
-		state.EDSMTarget.RemainingJumpsInRoute = int(jumps)
+func eFSDTarget(e *FSDTargetEvent, state *Journalstate) {
+	state.EDSMTarget.SystemAddress = e.SystemAddress
+	state.EDSMTarget.Name = e.Name
+	if e.RemainingJumpsInRoute != nil && e.SystemAddress != 0 {
+		state.EDSMTarget.RemainingJumpsInRoute = *e.RemainingJumpsInRoute
 	} else {
 		state.EDSMTarget.RemainingJumpsInRoute = 0
 	}
@@ -453,93 +346,92 @@ func eFSDTarget(p parser, state *Journalstate) {
 	state.ArrivedAtFSDTargetTime = time.Time{}
 }
 
-func eApproachBody(p parser, state *Journalstate) {
-	state.Location.Body, _ = p.getString(body)
-	state.Location.BodyID, _ = p.getInt(bodyid)
+func eApproachBody(e *ApproachBodyEvent, state *Journalstate) {
+	state.Location.Body = e.Body
+	state.Location.BodyID = e.BodyID
 
 	state.Type = LocationPlanet
 }
 
-func eApproachSettlement(p parser, state *Journalstate) {
-	state.Location.Body, _ = p.getString(bodyname)
-	state.Location.BodyID, _ = p.getInt(bodyid)
+func eApproachSettlement(e *ApproachSettlementEvent, state *Journalstate) {
+	state.Location.Body = e.BodyName
+	state.Location.BodyID = e.BodyID
 
 	state.Type = LocationPlanet
 }
 
-func eLoadout(p parser) {
-	capacity, ok := p.getInt("CargoCapacity")
-	if ok {
-		currentCargoCapacity = int(capacity)
+func eLoadout(e *LoadoutEvent, state *Journalstate) {
+	if e.CargoCapacity != nil {
+		state.LoadoutCargoCapacity = *e.CargoCapacity
 	}
 }
 
-func eDocked(p parser, state *Journalstate) {
-	stationName, _ := p.getString("StationName")
-	stationType, _ := p.getString("StationType")
-	systemAddress, _ := p.getInt("SystemAddress")
-	systemName, _ := p.getString("StarSystem")
+func eNavRouteClear(e *NavRouteClearEvent, state *Journalstate) {
+	state.EDSMTarget = EDSMTarget{}
+	state.LastFSDTargetSystem = ""
+	state.LastFSDTargetAddress = 0
+	state.ArrivedAtFSDTarget = false
+	state.ArrivedAtFSDTargetTime = time.Time{}
+}
 
+func eDocked(e *DockedEvent, state *Journalstate) {
 	state.Type = LocationDocked
-	state.Location.Body = stationName
+	state.Location.Body = e.StationName
 	state.Location.BodyID = 0
-	state.Location.SystemAddress = systemAddress
-	state.Location.StarSystem = systemName
+	state.Location.SystemAddress = e.SystemAddress
+	state.Location.StarSystem = e.StarSystem
 	state.BodyType = "Station"
 
 	// --- Fleet Carrier: store last FC name for this ID if docking at FC ---
-	if stationType == "FleetCarrier" {
+	if e.StationType == "FleetCarrier" {
 		// Try to get last seen FC name from ReceiveText, else leave as is
 		// (Display logic will handle fallback)
 	}
 }
 
 // --- Fleet Carrier: parse ReceiveText for FC name ---
-func eReceiveText(p parser) {
-	from, _ := p.getString("From")
-	message, _ := p.getString("Message")
-	channel, _ := p.getString("Channel")
-	if channel == "npc" && strings.HasSuffix(message, "docking_granted;") {
+func eReceiveText(e *ReceiveTextEvent, state *Journalstate) {
+	if e.Channel == "npc" && strings.HasSuffix(e.Message, "docking_granted;") {
 		// Only store if looks like FC docking granted
-		SaveFleetCarrierReceiveText(from)
+		state.SaveFleetCarrierReceiveText(e.From)
 	}
 }
 
-func checkArrival() {
+func checkArrival(state *Journalstate) {
 	// Only clear arrival state if a new target is set, or N seconds have passed
 	const arrivalTimeout = 10 * time.Second // <-- Change this value as desired
-	if lastJournalState.ArrivedAtFSDTarget {
-		if lastJournalState.EDSMTarget.SystemAddress != 0 || // new FSD target
-			lastJournalState.Destination.SystemAddress != 0 || // local target
-			(!lastJournalState.ArrivedAtFSDTargetTime.IsZero() &&
-				time.Since(lastJournalState.ArrivedAtFSDTargetTime) > arrivalTimeout) {
-			lastJournalState.ArrivedAtFSDTarget = false
-			lastJournalState.ArrivedAtFSDTargetTime = time.Time{}
+	if state.ArrivedAtFSDTarget {
+		if state.EDSMTarget.SystemAddress != 0 || // new FSD target
+			state.Destination.SystemAddress != 0 || // local target
+			(!state.ArrivedAtFSDTargetTime.IsZero() &&
+				time.Since(state.ArrivedAtFSDTargetTime) > arrivalTimeout) {
+			state.ArrivedAtFSDTarget = false
+			state.ArrivedAtFSDTargetTime = time.Time{}
 		}
 	}
 }
 
-func checkSplashScreen() {
+func (r *Reader) checkSplashScreen() {
 	const splashTimeout = 10 * time.Second
-	if lastJournalState.ShowSplashScreen {
-		timeoutPassed := time.Since(lastJournalState.SplashScreenStartTime) > splashTimeout
+	if r.state.ShowSplashScreen {
+		timeoutPassed := time.Since(r.state.SplashScreenStartTime) > splashTimeout
 
 		firstPageReady := false
-		switch firstEnabledPageKey {
+		switch r.firstEnabledPage {
 		case "destination":
-			firstPageReady = lastJournalState.Destination.SystemAddress != 0 ||
-				lastJournalState.EDSMTarget.SystemAddress != 0 ||
-				(lastJournalState.Type == LocationDocked && lastJournalState.Location.Body != "")
+			firstPageReady = r.state.Destination.SystemAddress != 0 ||
+				r.state.EDSMTarget.SystemAddress != 0 ||
+				(r.state.Type == LocationDocked && r.state.Location.Body != "")
 		case "location":
-			firstPageReady = lastJournalState.Location.SystemAddress != 0
+			firstPageReady = r.state.Location.SystemAddress != 0
 		case "cargo":
-			firstPageReady = len(currentCargo.Inventory) > 0
+			firstPageReady = len(r.state.Cargo.Inventory) > 0
 		default:
 			firstPageReady = true // fallback: don't block forever
 		}
 
 		if timeoutPassed && firstPageReady {
-			lastJournalState.ShowSplashScreen = false
+			r.state.ShowSplashScreen = false
 		}
 	}
-}
\ No newline at end of file
+}
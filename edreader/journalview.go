@@ -9,7 +9,6 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
-	lcdformat "github.com/pbxx/goLCDFormat"
 	"github.com/pellux-network/EDx52display/edsm"
 	"github.com/pellux-network/EDx52display/mfd"
 )
@@ -38,7 +37,7 @@ func GetEDSMSystemValue(systemaddress int64) (*edsm.System, error) {
 }
 
 // Helper to render a station page
-func RenderStationPage(page *mfd.Page, header string, st edsm.Station) {
+func RenderStationPage(page *mfd.Page, header string, st edsm.Station, g mfd.Geometry) {
 	// Map allegiance to abbreviation
 	abbr := map[string]string{
 		"Federation":  "FED",
@@ -51,13 +50,23 @@ func RenderStationPage(page *mfd.Page, header string, st edsm.Station) {
 	if alg == "" {
 		alg = st.Allegiance // fallback to raw if not mapped
 	}
-	page.Add(lcdformat.SpaceBetween(16, header, alg))
-	page.Add(st.Name)
-	page.Add(st.Type)
+	page.Icon = mfd.IconStation
+	page.AddRow(g.Width, mfd.Row{Left: header, Right: mfd.Transliterate(alg)})
+	page.AddRow(g.Width, mfd.Row{Style: mfd.RowTitle, Left: st.Name})
+	page.Add("%s", st.Type)
+
+	// Detail view
+	page.Detail = []string{
+		page.Lines[0],
+		st.Name,
+		st.Type,
+		st.Allegiance,
+		mfd.SpaceBetween(g.Width, "DIST", fmt.Sprintf("%.0f LS", st.DistanceToArrival)),
+	}
 }
 
 // Helper to render a Fleet Carrier page
-func RenderFleetCarrierPage(page *mfd.Page, header, fcID, fcName string, systemAddress int64) {
+func RenderFleetCarrierPage(page *mfd.Page, header, fcID, fcName string, systemAddress int64, g mfd.Geometry) {
 	// Try to get EDSM station info for type (always "Fleet Carrier" but future-proof)
 	stType := "Fleet Carrier"
 	stations, err := edsm.GetSystemStations(systemAddress)
@@ -69,13 +78,14 @@ func RenderFleetCarrierPage(page *mfd.Page, header, fcID, fcName string, systemA
 			}
 		}
 	}
-	page.Add(lcdformat.SpaceBetween(16, header, fcID))
-	page.Add(fcName)
-	page.Add(stType)
+	page.Icon = mfd.IconStation
+	page.AddRow(g.Width, mfd.Row{Left: header, Right: fcID})
+	page.AddRow(g.Width, mfd.Row{Style: mfd.RowTitle, Left: fcName})
+	page.Add("%s", stType)
 }
 
 // Page rendering functions for MFD
-func RenderLocationPage(page *mfd.Page, state Journalstate) {
+func RenderLocationPage(page *mfd.Page, state Journalstate, g mfd.Geometry) {
 	// --- Fleet Carrier: CURR FC page ---
 	if state.Type == LocationDocked && state.Location.Body != "" && state.BodyType == "Station" {
 		// Try to detect if docked at FC
@@ -93,11 +103,11 @@ func RenderLocationPage(page *mfd.Page, state Journalstate) {
 		if isFC || strings.HasPrefix(state.Location.Body, "FC") || len(state.Location.Body) == 7 {
 			// Try to get last seen FC name from session
 			fcID := state.Location.Body
-			fcName := GetLastFleetCarrierName(fcID)
+			fcName := state.GetLastFleetCarrierName(fcID)
 			if fcName == "" {
 				fcName = "Unknown Fleet Carrier"
 			}
-			RenderFleetCarrierPage(page, "CURR FC", fcID, fcName, state.Location.SystemAddress)
+			RenderFleetCarrierPage(page, "CURR FC", fcID, fcName, state.Location.SystemAddress, g)
 			return
 		}
 		// ...existing code for normal stations...
@@ -105,7 +115,7 @@ func RenderLocationPage(page *mfd.Page, state Journalstate) {
 		if err == nil {
 			for _, st := range stations {
 				if strings.EqualFold(st.Name, state.Location.Body) {
-					RenderStationPage(page, "CURR PORT", st)
+					RenderStationPage(page, "CURR PORT", st, g)
 					return
 				}
 			}
@@ -113,26 +123,13 @@ func RenderLocationPage(page *mfd.Page, state Journalstate) {
 		// fallback: show as body if not found as station
 	}
 	if state.Type == LocationPlanet || state.Type == LocationLanded {
-		ApplyBodyPage(page, "CURR BODY", state.Location.SystemAddress, state.Location.BodyID, state.Location.Body)
+		ApplyBodyPage(page, "CURR BODY", state.Location.SystemAddress, state.Location.BodyID, state.Location.Body, g)
 	} else {
-		ApplySystemPage(page, "CURR SYSTEM", state.Location.StarSystem, state.Location.SystemAddress, &state)
+		ApplySystemPage(page, "CURR SYSTEM", state.Location.StarSystem, state.Location.SystemAddress, &state, g)
 	}
 }
 
-func RenderDestinationPage(page *mfd.Page, state Journalstate) {
-	if state.ShowSplashScreen {
-		page.Add("################")
-		page.Add("EDx52display v0.2.4")
-		page.Add("################")
-		return
-	}
-	if state.ArrivedAtFSDTarget {
-		page.Add("################")
-		page.Add("  You have arrived  ")
-		page.Add("################")
-		return
-	}
-
+func RenderDestinationPage(page *mfd.Page, state Journalstate, g mfd.Geometry) {
 	// Local destination in current system
 	if state.Destination.SystemAddress != 0 &&
 		state.Destination.SystemAddress == state.Location.SystemAddress &&
@@ -145,7 +142,7 @@ func RenderDestinationPage(page *mfd.Page, state Journalstate) {
 			if fcName == "" {
 				fcName = "Unknown Fleet Carrier"
 			}
-			RenderFleetCarrierPage(page, "TGT FC", fcID, fcName, state.Destination.SystemAddress)
+			RenderFleetCarrierPage(page, "TGT FC", fcID, fcName, state.Destination.SystemAddress, g)
 			return
 		}
 
@@ -154,7 +151,7 @@ func RenderDestinationPage(page *mfd.Page, state Journalstate) {
 		if err == nil {
 			for _, st := range stations {
 				if strings.EqualFold(st.Name, state.Destination.Name) {
-					RenderStationPage(page, "TGT PORT", st)
+					RenderStationPage(page, "TGT PORT", st, g)
 					return
 				}
 			}
@@ -167,71 +164,78 @@ func RenderDestinationPage(page *mfd.Page, state Journalstate) {
 				body := sys.BodyByID(state.Destination.BodyID)
 				switch {
 				case body.IsLandable:
-					ApplyBodyPage(page, "TGT BODY", state.Location.SystemAddress, state.Destination.BodyID, state.Destination.Name)
+					ApplyBodyPage(page, "TGT BODY", state.Location.SystemAddress, state.Destination.BodyID, state.Destination.Name, g)
 					return
 				default:
-					page.Add(lcdformat.SpaceBetween(16, "TGT BODY", state.Destination.Name))
+					page.Icon = mfd.IconPlanet
+					page.AddRow(g.Width, mfd.Row{Left: "TGT BODY", Right: mfd.Transliterate(state.Destination.Name)})
 					if body.SubType != "" {
-						page.Add(body.SubType)
+						page.Add("%s", body.SubType)
 					}
 					return
 				}
 			}
 		}
 		// Fallback if EDSM fails or no BodyID
-		page.Add(lcdformat.SpaceBetween(16, "TGT BODY", state.Destination.Name))
+		page.Icon = mfd.IconPlanet
+		page.AddRow(g.Width, mfd.Row{Left: "TGT BODY", Right: mfd.Transliterate(state.Destination.Name)})
 		return
 	}
 
 	// FSD target (next jump)
 	if state.EDSMTarget.SystemAddress != 0 {
-		ApplySystemPage(page, "NEXT JUMP", state.EDSMTarget.Name, state.EDSMTarget.SystemAddress, &state)
+		ApplySystemPage(page, "NEXT JUMP", state.EDSMTarget.Name, state.EDSMTarget.SystemAddress, &state, g)
 		return
 	}
 
-	page.Add(" No Destination ")
+	page.Add("%s", mfd.Center(g.Width, "No Destination"))
 }
 
-func RenderCargoPage(page *mfd.Page, _ Journalstate) {
-	lines := []string{}
+func RenderCargoPage(page *mfd.Page, state Journalstate, g mfd.Geometry) {
+	cargo := state.Cargo
 	// Cargo header
-	lines = append(lines, fmt.Sprintf("CARGO: %04d/%04d", currentCargo.Count, ModulesInfoCargoCapacity()))
-	// If currentCargo is nil (never loaded), show "No cargo data"
-	if currentCargo.Inventory == nil {
-		lines = append(lines, lcdformat.FillAround(16, "*", " NO CRGO DATA "))
-		for _, line := range lines {
-			page.Add(line)
-		}
+	page.Icon = mfd.IconCargo
+	page.AddRow(g.Width, mfd.Row{Left: "CARGO:", Right: fmt.Sprintf("%04d/%04d", cargo.Count, state.CargoCapacity())})
+	page.Gauge = &mfd.Gauge{Value: cargo.Count, Max: state.CargoCapacity()}
+	// If the cargo is nil (never loaded), show "No cargo data"
+	if cargo.Inventory == nil {
+		page.AddRow(g.Width, mfd.Row{Style: mfd.RowSeparator, Left: "NO CRGO DATA"})
 		return
 	}
 
-	if len(currentCargo.Inventory) == 0 {
+	if len(cargo.Inventory) == 0 {
 		// If cargo inventory is empty, show "Cargo Hold Empty"
-		lines = append(lines, lcdformat.FillAround(16, "*", " NO CARGO "))
-		for _, line := range lines {
-			page.Add(line)
-		}
+		page.AddRow(g.Width, mfd.Row{Style: mfd.RowSeparator, Left: "NO CARGO"})
 		return
 	}
-	sort.Slice(currentCargo.Inventory, func(i, j int) bool {
-		a := currentCargo.Inventory[i]
-		b := currentCargo.Inventory[j]
+	sort.Slice(cargo.Inventory, func(i, j int) bool {
+		a := cargo.Inventory[i]
+		b := cargo.Inventory[j]
 		return a.displayname() < b.displayname()
 	})
 
-	for _, line := range currentCargo.Inventory {
-		lines = append(lines, lcdformat.SpaceBetween(16, line.displayname(), printer.Sprintf("%d", line.Count)))
-	}
-	// Add all pages in slice to the MFD
-	for _, line := range lines {
-		page.Add(line)
+	// Each commodity opens its details
+	for _, line := range cargo.Inventory {
+		page.AddKeyedRow(line.Name, g.Width, mfd.Row{Left: line.displayname(), Right: printer.Sprintf("%d", line.Count)})
+		page.AddChild(cargoDetailPage(line, g))
 	}
 }
 
+// Child view with the details of a single commodity in the hold
+func cargoDetailPage(line CargoLine, g mfd.Geometry) mfd.Page {
+	child := mfd.NewPage()
+	child.Icon = mfd.IconCargo
+	child.Add("%s", line.displayname())
+	child.AddRow(g.Width, mfd.Row{Left: "Count:", Right: printer.Sprintf("%d", line.Count)})
+	child.AddRow(g.Width, mfd.Row{Left: "Stolen:", Right: printer.Sprintf("%d", line.Stolen)})
+	child.AddRow(g.Width, mfd.Row{Left: "Legal:", Right: printer.Sprintf("%d", line.Count-line.Stolen)})
+	return child
+}
+
 // Page assembly functions for MFD
-func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress int64, state *Journalstate) {
-	// Initialize a slice to hold lines for the page
-	lines := []string{}
+func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress int64, state *Journalstate, g mfd.Geometry) {
+	// Initialize a slice to hold the rows of the page
+	rows := []mfd.Row{}
 	// Fetch system body information
 	sys, err := GetEDSMBodies(systemaddress)
 	if err != nil {
@@ -247,24 +251,16 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 	}
 
 	mainBody := sys.MainStar()
-	// Separate the header (classification) and header display
-	newHeader := header
+	page.Icon = mfd.IconStar
 	// Format the header based on the header title
-	if header == "NEXT JUMP" || header == "CURR SYSTEM" {
+	if (header == "NEXT JUMP" || header == "CURR SYSTEM") && mainBody.IsScoopable {
 		// Add FUEL indicator if star is scoopable
-		if mainBody.IsScoopable {
-
-			newHeader = lcdformat.SpaceBetween(16, header, "FUEL")
-			lines = append(lines, newHeader)
-		} else {
-			lines = append(lines, header)
-		}
-
+		rows = append(rows, mfd.Row{Left: header, Right: "FUEL", RightIcon: mfd.IconFuel})
 	} else {
-		lines = append(lines, header)
+		rows = append(rows, mfd.Row{Left: header})
 	}
 	// Add the system name line to the page
-	lines = append(lines, systemname)
+	rows = append(rows, mfd.Row{Style: mfd.RowTitle, Left: systemname})
 
 	// Add the star class and remaining jumps
 	// page.Add("Star: %s", mainBody.SubType)
@@ -274,23 +270,34 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 	if state != nil && header == "NEXT JUMP" {
 		jumps = fmt.Sprintf("J:%d", state.EDSMTarget.RemainingJumpsInRoute)
 	}
-	lines = append(lines, lcdformat.SpaceBetween(16, fmt.Sprintf("CLS:%s", starTypeData.Class), jumps))
+	rows = append(rows, mfd.Row{Left: fmt.Sprintf("CLS:%s", starTypeData.Class), Right: jumps})
 	// Add the main star information
-	lines = append(lines, starTypeData.Desc)
+	rows = append(rows, mfd.Row{Left: starTypeData.Desc})
 	// Add system body count and estimated values
 
-	lines = append(lines, lcdformat.SpaceBetween(16, "Bodies:", printer.Sprintf("%d", sys.BodyCount)))
-	lines = append(lines, lcdformat.SpaceBetween(16, "Scan:", printer.Sprintf("%dcr", values.EstimatedValue)))
-	lines = append(lines, lcdformat.SpaceBetween(16, "Map:", printer.Sprintf("%dcr", values.EstimatedValueMapped)))
+	rows = append(rows, mfd.Row{Left: "Bodies:", Right: printer.Sprintf("%d", sys.BodyCount)})
+	rows = append(rows, mfd.Row{Left: "Scan:", Right: printer.Sprintf("%dcr", values.EstimatedValue)})
+	rows = append(rows, mfd.Row{Left: "Map:", Right: printer.Sprintf("%dcr", values.EstimatedValueMapped)})
 
-	// Print valuable bodies if available
+	// Print valuable bodies if available, each opening its full body page
+	children := map[int]mfd.Page{}
+	keys := map[int]string{}
 	if len(values.ValuableBodies) > 0 {
-		lines = append(lines, lcdformat.FillAround(16, "*", " VAL BODIES "))
+		rows = append(rows, mfd.Row{Style: mfd.RowSeparator, Left: "VAL BODIES"})
 		for _, valbody := range values.ValuableBodies {
 			bodyName := valbody.ShortName(*sys)
 			crValue := printer.Sprintf("%dcr", valbody.ValueMax)
-			// append the body name and value to the lines
-			lines = append(lines, lcdformat.SpaceBetween(16, bodyName, crValue))
+			for _, body := range sys.Bodies {
+				if body.Name == valbody.BodyName {
+					child := mfd.NewPage()
+					ApplyBodyPage(&child, "VAL BODY", systemaddress, body.BodyID, bodyName, g)
+					children[len(rows)] = child
+					break
+				}
+			}
+			// append the body name and value to the rows
+			keys[len(rows)] = valbody.BodyName
+			rows = append(rows, mfd.Row{Left: mfd.Transliterate(bodyName), Right: crValue})
 		}
 	}
 
@@ -314,7 +321,7 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 
 	// Add prospecting information if landable bodies are present
 	// if len(landables) > 0 {
-	// 	lines = append(lines, lcdformat.FillAround(16, "*", " PROSPECT "))
+	// 	lines = append(lines, mfd.FillAround(16, "*", " PROSPECT "))
 	// 	materialList := []string{}
 
 	// 	for mat := range matLocations {
@@ -341,52 +348,49 @@ func ApplySystemPage(page *mfd.Page, header, systemname string, systemaddress in
 	// 		lines = append(lines, fmt.Sprintf("%s %d", material, len(bodiesWithMat)))
 	// 		b := bodiesWithMat[0]
 	// 		// Add the body name (number usually) and material percentage
-	// 		// matLine := lcdformat.SpaceBetween(16, b.ShortName(*sys), fmt.Sprintf("%.2f%%", float64(b.Materials[material])))
-	// 		matLine := lcdformat.SpaceBetween(16, b.ShortName(*sys), fmt.Sprintf("%.2f%%%%", b.Materials[material]))
+	// 		// matLine := mfd.SpaceBetween(16, b.ShortName(*sys), fmt.Sprintf("%.2f%%", float64(b.Materials[material])))
+	// 		matLine := mfd.SpaceBetween(16, b.ShortName(*sys), fmt.Sprintf("%.2f%%%%", b.Materials[material]))
 	// 		lines = append(lines, matLine)
 	// 	}
 	// } else {
 	// 	return
 	// }
 
-	// Add all pages in slice to the MFD
-	for _, line := range lines {
-		page.Add(line)
+	// Add all rows in slice to the MFD
+	for i, row := range rows {
+		if key, ok := keys[i]; ok {
+			page.AddKeyedRow(key, g.Width, row)
+		} else {
+			page.AddRow(g.Width, row)
+		}
+		if child, ok := children[i]; ok {
+			page.AddChild(child)
+		}
 	}
 }
 
-func ApplyBodyPage(page *mfd.Page, header string, systemAddress int64, bodyID int64, bodyName string) {
-	lines := []string{}
-
+func ApplyBodyPage(page *mfd.Page, header string, systemAddress int64, bodyID int64, bodyName string, g mfd.Geometry) {
 	sys, err := GetEDSMBodies(systemAddress)
 	if err != nil {
 		log.Println("Error fetching EDSM data: ", err)
-		lines = append(lines, lcdformat.FillAround(16, "*", " EDSM ERROR "))
-		for _, line := range lines {
-			page.Add(line)
-		}
+		page.AddRow(g.Width, mfd.Row{Style: mfd.RowSeparator, Left: "EDSM ERROR"})
 		return
 	}
 
 	body := sys.BodyByID(bodyID)
 	if body.BodyID == 0 {
-		lines = append(lines, lcdformat.FillAround(16, "*", " NO BODY DATA "))
-		for _, line := range lines {
-			page.Add(line)
-		}
+		page.AddRow(g.Width, mfd.Row{Style: mfd.RowSeparator, Left: "NO BODY DATA"})
 		return
 	}
-	lines = append(lines, lcdformat.SpaceBetween(16, header, fmt.Sprintf("%.2fG", body.Gravity)))
-	lines = append(lines, bodyName)
-	lines = append(lines, cases.Title(language.English).String(body.SubType))
+	page.Icon = mfd.IconPlanet
+	page.AddRow(g.Width, mfd.Row{Left: header, Right: fmt.Sprintf("%.2fG", body.Gravity)})
+	page.AddRow(g.Width, mfd.Row{Style: mfd.RowTitle, Left: bodyName})
+	page.Add("%s", cases.Title(language.English).String(body.SubType))
 
 	// add the planet materials
-	lines = append(lines, lcdformat.FillAround(16, "*", " MATERIAL "))
+	page.AddRow(g.Width, mfd.Row{Style: mfd.RowSeparator, Left: "MATERIAL"})
 	for _, m := range body.MaterialsSorted() {
-		lines = append(lines, lcdformat.SpaceBetween(16, fmt.Sprintf("%5.2f%%%%", m.Percentage), m.Name))
-	}
-	for _, line := range lines {
-		page.Add(line)
+		page.AddRow(g.Width, mfd.Row{Left: fmt.Sprintf("%5.2f%%", m.Percentage), Right: m.Name})
 	}
 }
 
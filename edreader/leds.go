@@ -0,0 +1,124 @@
+package edreader
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/pellux-network/EDx52display/conf"
+	"github.com/pellux-network/EDx52display/mfd"
+)
+
+// Status.json flags, see https://elite-journal.readthedocs.io/en/latest/Status%20File/
+const (
+	FlagDocked          = 1 << 0
+	FlagLanded          = 1 << 1
+	FlagLandingGear     = 1 << 2
+	FlagShieldsUp       = 1 << 3
+	FlagSupercruise     = 1 << 4
+	FlagFlightAssistOff = 1 << 5
+	FlagHardpoints      = 1 << 6
+	FlagInWing          = 1 << 7
+	FlagLightsOn        = 1 << 8
+	FlagCargoScoop      = 1 << 9
+	FlagSilentRunning   = 1 << 10
+	FlagScoopingFuel    = 1 << 11
+	FlagMassLocked      = 1 << 16
+	FlagFSDCharging     = 1 << 17
+	FlagFSDCooldown     = 1 << 18
+	FlagLowFuel         = 1 << 19
+	FlagOverHeating     = 1 << 20
+	FlagInDanger        = 1 << 22
+	FlagInterdicted     = 1 << 23
+	FlagAnalysisMode    = 1 << 27
+	FlagNightVision     = 1 << 28
+)
+
+func statusFlag(flag int64) func(Journalstate) bool {
+	return func(state Journalstate) bool {
+		return state.StatusFlags&flag != 0
+	}
+}
+
+// ledConditions are the game states LED rules can react to
+var ledConditions = map[string]func(Journalstate) bool{
+	"docked": func(state Journalstate) bool {
+		return state.StatusFlags&FlagDocked != 0 || state.Type == LocationDocked
+	},
+	"landed": func(state Journalstate) bool {
+		return state.StatusFlags&FlagLanded != 0 || state.Type == LocationLanded
+	},
+	"landinggear":     statusFlag(FlagLandingGear),
+	"shieldsup":       statusFlag(FlagShieldsUp),
+	"supercruise":     statusFlag(FlagSupercruise),
+	"flightassistoff": statusFlag(FlagFlightAssistOff),
+	"hardpoints":      statusFlag(FlagHardpoints),
+	"inwing":          statusFlag(FlagInWing),
+	"lights":          statusFlag(FlagLightsOn),
+	"cargoscoop":      statusFlag(FlagCargoScoop),
+	"silentrunning":   statusFlag(FlagSilentRunning),
+	"scoopingfuel":    statusFlag(FlagScoopingFuel),
+	"masslocked":      statusFlag(FlagMassLocked),
+	"fsdcharging":     statusFlag(FlagFSDCharging),
+	"fsdcooldown":     statusFlag(FlagFSDCooldown),
+	"lowfuel":         statusFlag(FlagLowFuel),
+	"overheating":     statusFlag(FlagOverHeating),
+	"danger":          statusFlag(FlagInDanger),
+	"interdicted":     statusFlag(FlagInterdicted),
+	"analysismode":    statusFlag(FlagAnalysisMode),
+	"nightvision":     statusFlag(FlagNightVision),
+	"fsdtarget": func(state Journalstate) bool {
+		return state.EDSMTarget.SystemAddress != 0
+	},
+	"arrived": func(state Journalstate) bool {
+		return state.ArrivedAtFSDTarget
+	},
+}
+
+type ledRule struct {
+	when  func(Journalstate) bool
+	led   mfd.Led
+	color mfd.LedColor
+	blink bool
+}
+
+// loadLedRules parses the configured LED rules, skipping invalid ones
+func loadLedRules(rules []conf.LedRule) []ledRule {
+	var ledRules []ledRule
+	for _, r := range rules {
+		when, ok := ledConditions[r.When]
+		if !ok {
+			log.Warnf("Ignoring LED rule: unknown condition %q", r.When)
+			continue
+		}
+		led, err := mfd.ParseLed(r.Led)
+		if err != nil {
+			log.Warnln("Ignoring LED rule:", err)
+			continue
+		}
+		color, err := mfd.ParseLedColor(r.Color)
+		if err != nil {
+			log.Warnln("Ignoring LED rule:", err)
+			continue
+		}
+		ledRules = append(ledRules, ledRule{when: when, led: led, color: color, blink: r.Blink})
+	}
+	return ledRules
+}
+
+// updateLeds applies the LED rules to the given state. The first matching rule for an LED wins,
+// LEDs that have rules but none matching are turned off.
+func updateLeds(ledRules []ledRule, state Journalstate) {
+	applied := map[mfd.Led]bool{}
+	for _, r := range ledRules {
+		if applied[r.led] || !r.when(state) {
+			continue
+		}
+		applied[r.led] = true
+		mfd.SetLed(r.led, r.color, r.blink)
+	}
+	for _, r := range ledRules {
+		if !applied[r.led] {
+			applied[r.led] = true
+			mfd.SetLed(r.led, mfd.LedOff, false)
+		}
+	}
+}
@@ -20,26 +20,29 @@ type ModulesLine struct {
 	Item string
 }
 
-var currentModules ModulesInfo
-var currentCargoCapacity int // NEW: updated from Loadout event
-
-func handleModulesInfoFile(file string) {
+func (r *Reader) handleModulesInfoFile(file string) {
 	data, err := os.ReadFile(file)
 	if err != nil {
 		log.Errorln(err)
 		return
 	}
 
-	json.Unmarshal(data, &currentModules)
+	json.Unmarshal(data, &r.state.Modules)
 }
 
-func ModulesInfoCargoCapacity() int {
-	if currentCargoCapacity > 0 {
-		return currentCargoCapacity
+// CargoCapacity returns the cargo capacity of the ship, from the Loadout event or else the fitted cargo racks
+func (state Journalstate) CargoCapacity() int {
+	if state.LoadoutCargoCapacity > 0 {
+		return state.LoadoutCargoCapacity
 	}
+	return state.Modules.CargoCapacity()
+}
+
+// CargoCapacity adds up the capacity of the fitted cargo racks
+func (m ModulesInfo) CargoCapacity() int {
 	cargoCapacity := 0
 
-	for _, line := range currentModules.Modules {
+	for _, line := range m.Modules {
 		switch line.Item {
 		case "int_cargorack_size1_class1":
 			cargoCapacity += 2
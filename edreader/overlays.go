@@ -0,0 +1,58 @@
+package edreader
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pellux-network/EDx52display/mfd"
+)
+
+// Overlay IDs and priorities of the transient screens
+const (
+	overlaySplash  = "splash"
+	overlayArrival = "arrival"
+
+	prioritySplash  = 100
+	priorityArrival = 50
+)
+
+// How long the arrival screen is shown
+const arrivalDuration = 10 * time.Second
+
+// postSplashScreen shows the splash screen on the first enabled page until it has something to show.
+// The other pages are shown right away. Without any of the pages the splash screen waits for, there is none.
+func (r *Reader) postSplashScreen() {
+	if r.firstEnabledPage == "" {
+		return
+	}
+	border := strings.Repeat("#", mfd.LayoutGeometry().Width)
+	mfd.PostOverlay(mfd.Overlay{
+		ID:       overlaySplash,
+		Lines:    []string{border, "EDx52display v0.2.4", border},
+		Priority: prioritySplash,
+		Page:     r.firstEnabledPage,
+	})
+}
+
+// updateOverlays posts and clears the transient screens following the game state
+func (r *Reader) updateOverlays(state Journalstate) {
+	if !state.ShowSplashScreen {
+		mfd.ClearOverlay(overlaySplash)
+	}
+	switch {
+	case state.ArrivedAtFSDTarget && !r.arrivalPosted:
+		g := mfd.LayoutGeometry()
+		border := strings.Repeat("#", g.Width)
+		mfd.PostOverlay(mfd.Overlay{
+			ID:       overlayArrival,
+			Lines:    []string{border, mfd.Center(g.Width, "You have arrived"), border},
+			Priority: priorityArrival,
+			Duration: arrivalDuration,
+			Page:     string(PageDestination),
+		})
+		r.arrivalPosted = true
+	case !state.ArrivedAtFSDTarget && r.arrivalPosted:
+		mfd.ClearOverlay(overlayArrival)
+		r.arrivalPosted = false
+	}
+}
@@ -0,0 +1,39 @@
+package edreader
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// tailLines calls fn with every complete line read from r, without its line ending, and returns the number
+// of bytes those lines take up. A last line without a newline is left alone, as the game may still be
+// writing it; it is read in full once its newline arrives. Lines can be of any length.
+// fn must not keep the line, it is overwritten by the next one.
+func tailLines(r io.Reader, fn func(line []byte)) (int64, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var read int64
+	var long []byte
+	for {
+		line, err := br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Longer than the buffer, collect the line until its end
+			long = append(long[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = br.ReadSlice('\n')
+				long = append(long, line...)
+			}
+			line = long
+		}
+		if err == io.EOF {
+			return read, nil
+		}
+		if err != nil {
+			return read, err
+		}
+		read += int64(len(line))
+		if line = bytes.TrimRight(line, "\r\n"); len(line) > 0 {
+			fn(line)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package edreader
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func collectLines(t *testing.T, r io.Reader) ([]string, int64, error) {
+	t.Helper()
+	var lines []string
+	n, err := tailLines(r, func(line []byte) {
+		lines = append(lines, string(line))
+	})
+	return lines, n, err
+}
+
+func TestTailLinesLeavesPartialLine(t *testing.T) {
+	lines, n, err := collectLines(t, strings.NewReader("{\"a\":1}\r\n\n{\"b\":2}\n{\"c\":"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, "|") != `{"a":1}|{"b":2}` {
+		t.Errorf("got lines %q", lines)
+	}
+	if want := int64(len("{\"a\":1}\r\n\n{\"b\":2}\n")); n != want {
+		t.Errorf("read %d bytes, wanted %d", n, want)
+	}
+}
+
+func TestTailLinesLongLine(t *testing.T) {
+	long := `{"event":"Loadout","Modules":"` + strings.Repeat("x", 300*1024) + `"}`
+	lines, n, err := collectLines(t, strings.NewReader(long+"\nnext\n"+long))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0] != long || lines[1] != "next" {
+		t.Errorf("got %d lines, wanted the long line and the next", len(lines))
+	}
+	if want := int64(len(long) + len("\nnext\n")); n != want {
+		t.Errorf("read %d bytes, wanted %d", n, want)
+	}
+}
+
+func TestTailLinesReportsErrors(t *testing.T) {
+	failure := errors.New("disk on fire")
+	lines, n, err := collectLines(t, io.MultiReader(strings.NewReader("one\ntw"), iotest.ErrReader(failure)))
+	if !errors.Is(err, failure) {
+		t.Errorf("got error %v, wanted %v", err, failure)
+	}
+	if len(lines) != 1 || n != 4 {
+		t.Errorf("got lines %q and %d bytes before the error", lines, n)
+	}
+}
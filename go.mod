@@ -8,9 +8,11 @@ require (
 	github.com/getlantern/systray v1.2.2
 	github.com/google/go-cmp v0.7.0
 	github.com/ncruces/zenity v0.10.14
-	github.com/pbxx/goLCDFormat v1.0.2
 	github.com/sirupsen/logrus v1.9.3
+	golang.org/x/image v0.20.0
+	golang.org/x/net v0.41.0
 	golang.org/x/sys v0.33.0
+	golang.org/x/term v0.32.0
 	golang.org/x/text v0.26.0
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1
 	gopkg.in/yaml.v2 v2.4.0
@@ -37,5 +39,4 @@ require (
 	go.opentelemetry.io/otel/trace v1.37.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
-	golang.org/x/image v0.20.0 // indirect
 )
@@ -0,0 +1,87 @@
+package mfd
+
+import "strconv"
+
+// anchorViews keeps the same lines in view on every page of a device after the display changed from old
+// to the current display. Must be called with the lock held.
+func anchorViews(d *deviceState, old map[string]Page) {
+	for key, oldPage := range old {
+		newPage, ok := pageIndex[key]
+		if !ok {
+			continue
+		}
+		anchorPage(d, key, oldPage, currentDisplay.Pages[newPage])
+	}
+}
+
+// anchorPage moves the scroll positions and the drill-down path of a page to where their lines went.
+// Must be called with the lock held.
+func anchorPage(d *deviceState, key string, oldPage, newPage Page) {
+	if d.detail && (len(oldPage.Detail) > 0 || len(newPage.Detail) > 0) {
+		// The detail view has no line keys
+		return
+	}
+	if line, ok := d.currentLines[key]; ok {
+		d.currentLines[key] = anchorLine(oldPage, newPage, line)
+	}
+	oldView, newView := key, key
+	path := d.paths[key]
+	for i, line := range path {
+		newLine, ok := findLine(newPage, lineKey(oldPage, line))
+		if !ok {
+			// The view is gone, trimPaths leaves it
+			break
+		}
+		oldChild, newChild := oldPage.Children[line], newPage.Children[newLine]
+		path[i] = newLine
+		oldView += "/" + strconv.Itoa(line)
+		newView += "/" + strconv.Itoa(newLine)
+		if scroll, ok := d.currentLines[oldView]; ok {
+			delete(d.currentLines, oldView)
+			d.currentLines[newView] = anchorLine(oldChild, newChild, scroll)
+		}
+		oldPage, newPage = oldChild, newChild
+	}
+}
+
+// anchorLine returns the line of the new page that shows the same content as a line of the old page.
+// The first keyed line at or below the line is followed, then the first keyed line above it. When the
+// keyed line itself is gone, the next keyed line takes its place. Without any line keys the line stays where it is.
+func anchorLine(oldPage, newPage Page, line uint32) uint32 {
+	keyed := lineKey(oldPage, int(line)) != ""
+	for i := int(line); i < len(oldPage.Lines); i++ {
+		if found, ok := findLine(newPage, lineKey(oldPage, i)); ok {
+			if keyed {
+				return uint32(found)
+			}
+			return uint32(max(found-(i-int(line)), 0))
+		}
+	}
+	for i := int(line) - 1; i >= 0; i-- {
+		if found, ok := findLine(newPage, lineKey(oldPage, i)); ok {
+			return uint32(found + int(line) - i)
+		}
+	}
+	return line
+}
+
+// lineKey returns the key of a line, or an empty string for lines without one
+func lineKey(page Page, line int) string {
+	if line < len(page.LineKeys) {
+		return page.LineKeys[line]
+	}
+	return ""
+}
+
+// findLine returns the index of the line with a key
+func findLine(page Page, key string) (int, bool) {
+	if key == "" {
+		return 0, false
+	}
+	for i, k := range page.LineKeys {
+		if k == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
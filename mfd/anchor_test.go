@@ -0,0 +1,53 @@
+package mfd
+
+import "testing"
+
+func cargoPage(items ...string) Page {
+	page := Page{Key: "first"}
+	page.Add("CARGO")
+	for _, item := range items {
+		page.AddKeyed(item, "%s", item)
+		page.AddChild(Page{Lines: []string{item + " details", "Count", "Stolen", "Legal"}})
+	}
+	return page
+}
+
+func TestScrollAnchoring(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	second := Page{Key: "second", Lines: []string{"Second"}}
+	UpdateDisplay(Display{Pages: []Page{cargoPage("Gold", "Silver", "Tea"), second}})
+
+	fake.PressButtons(FakeDevice, softButton_Down)
+	fake.PressButtons(FakeDevice, softButton_Down)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Silver" {
+		t.Fatalf("got %q after scrolling", got)
+	}
+
+	// A new item above keeps the same item in view
+	UpdateDisplay(Display{Pages: []Page{cargoPage("Beer", "Gold", "Silver", "Tea"), second}})
+	if got := fake.Line(FakeDevice, 0, 0); got != "Silver" {
+		t.Errorf("got %q after an item was added above, wanted %q", got, "Silver")
+	}
+
+	// When the item in view is gone, the next item takes its place
+	UpdateDisplay(Display{Pages: []Page{cargoPage("Beer", "Gold", "Tea"), second}})
+	if got := fake.Line(FakeDevice, 0, 0); got != "Tea" {
+		t.Errorf("got %q after the item in view was removed, wanted %q", got, "Tea")
+	}
+
+	// Child views follow their line too
+	setTestGestures(t, map[string]string{GestureClick: ActionOpen})
+	fake.PressButtons(FakeDevice, softButton_Select)
+	fake.PressButtons(FakeDevice, softButton_Down)
+	UpdateDisplay(Display{Pages: []Page{cargoPage("Beer", "Coffee", "Gold", "Tea"), second}})
+	if got := fake.Line(FakeDevice, 0, 0); got != "Count" {
+		t.Errorf("got %q after an item was added above the open child view, wanted %q", got, "Count")
+	}
+	if got := fake.Line(FakeDevice, 0, 2); got != "Legal" {
+		t.Errorf("got %q at the bottom of the child view", got)
+	}
+}
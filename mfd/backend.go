@@ -0,0 +1,55 @@
+package mfd
+
+import "image"
+
+// DeviceChangeFunc is called whenever a device is plugged in or removed
+type DeviceChangeFunc func(device uintptr, added bool)
+
+// PageChangeFunc is called whenever the page on a device changes
+type PageChangeFunc func(device uintptr, page uint32, active bool)
+
+// SoftButtonFunc is called whenever the soft buttons on a device are used
+type SoftButtonFunc func(device uintptr, buttons uint32)
+
+// Backend is a display driver the mfd package renders pages to.
+// The methods mirror the DirectOutput SDK so that the X52 Pro driver is just one implementation.
+type Backend interface {
+	// Initialize opens the connection to the driver
+	Initialize() error
+	// Deinitialize closes the connection to the driver
+	Deinitialize() error
+	// RegisterDeviceCallback sets the function called when a device is plugged in or removed
+	RegisterDeviceCallback(fn DeviceChangeFunc) error
+	// Enumerate calls fn for every device currently attached
+	Enumerate(fn func(device uintptr)) error
+	// GetSerialNumber returns the serial number of a device
+	GetSerialNumber(device uintptr) (string, error)
+	// RegisterPageCallback sets the function called when the page on the device changes
+	RegisterPageCallback(device uintptr, fn PageChangeFunc) error
+	// RegisterSoftButtonCallback sets the function called when the soft buttons on the device are used
+	RegisterSoftButtonCallback(device uintptr, fn SoftButtonFunc) error
+	// AddPage adds a page to the device, optionally making it the active one
+	AddPage(device uintptr, page uint32, active bool) error
+	// RemovePage removes a page from the device
+	RemovePage(device uintptr, page uint32) error
+	// SetString sets the text of a single line on a page
+	SetString(device uintptr, page, line uint32, text string) error
+	// SetLed sets the state of a single LED on a page
+	SetLed(device uintptr, page, led uint32, on bool) error
+}
+
+// ImageBackend is implemented by backends with devices that show images instead of text lines,
+// like the Saitek Flight Instrument Panel
+type ImageBackend interface {
+	// IsImageDevice reports whether a device shows images
+	IsImageDevice(device uintptr) bool
+	// SetImage sets the image shown on a page. The image is FIPWidth x FIPHeight pixels.
+	SetImage(device uintptr, page uint32, img image.Image) error
+}
+
+// PageBackend is implemented by backends that can switch the page shown on a device, such as the simulators.
+// Replaying a recording uses it to follow the recorded page changes.
+type PageBackend interface {
+	// SetPage shows a page on a device without calling the page callback
+	SetPage(device uintptr, page uint32) error
+}
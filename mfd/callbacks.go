@@ -1,6 +1,10 @@
 package mfd
 
-import log "github.com/sirupsen/logrus"
+import (
+	"slices"
+
+	log "github.com/sirupsen/logrus"
+)
 
 const (
 	softButton_Select = 0x00000001 // X52Pro ScrollClick
@@ -8,54 +12,70 @@ const (
 	softButton_Down   = 0x00000004 // X52Pro ScrollDown, FIP RightScrollAnticlockwize
 )
 
-// onEnumerate is called if a device is plugged in when the enumerate function is called.
-func onEnumerate(hdevice uintptr, context uintptr) uintptr {
+// onEnumerate is called for every device plugged in when the enumerate function is called.
+func onEnumerate(hdevice uintptr) {
+	mu.Lock()
+	defer mu.Unlock()
 	log.Debug("Found device")
-	device = hdevice
-	initPages()
-	return S_OK
+	attachDevice(hdevice)
 }
 
 // onDeviceChanged is called whenever a device is plugged in or removed
-func onDeviceChanged(hdevice uintptr, added bool, context uintptr) uintptr {
+func onDeviceChanged(hdevice uintptr, added bool) {
 	log.Traceln("onDeviceChanged", added)
+	mu.Lock()
+	defer mu.Unlock()
 	if added {
 		log.Debug("New device was plugged in")
-		device = hdevice
-		initPages()
+		attachDevice(hdevice)
 	} else {
-		device = 0
-		log.Warnln("Device was unplugged. You should restart this program.")
+		// Tear down so the pages and callbacks are set up again once the device is back
+		detachDevice(hdevice)
+		refreshDisplay(primaryDevice())
 	}
-
-	return S_OK
 }
 
 // onPageChange is called whenever the page scroll wheel is used.
-// The current (or last active) page is passed in the page parameter
+// The ID of the current (or last active) page is passed in the page parameter
 // The setActive flag indicates whether or not the new page is active (false if the profile page is set)
-func onPageChange(hdevice uintptr, page uint32, setActive bool, context uintptr) uintptr {
+func onPageChange(hdevice uintptr, page uint32, setActive bool) {
 	log.Traceln("onPageChange", page, setActive)
-	currentPage = page
-	pageActive = setActive
-	refreshDisplay()
+	mu.Lock()
+	defer mu.Unlock()
+	d, ok := devices[hdevice]
+	if !ok {
+		return
+	}
+	current := slices.IndexFunc(d.pages, func(key string) bool { return d.pageIDs[key] == page })
+	if current < 0 {
+		return
+	}
+	record(RecordedEvent{Type: EventPage, Serial: d.serial, Page: d.pages[current], Active: setActive})
+	touchDevice(d)
+	showPage(d, current, setActive)
+}
 
-	return S_OK
+// showPage makes a page the current page of a device. Must be called with the lock held.
+func showPage(d *deviceState, current int, active bool) {
+	d.currentPage = uint32(current)
+	d.pageActive = active
+	d.marqueeTick = 0
+	refreshDisplay(d)
 }
 
 // onSoftButton is called when the right scroll wheel is rolled or clicked
-func onSoftButton(hdevice uintptr, buttons uint32, context uintptr) uintptr {
+func onSoftButton(hdevice uintptr, buttons uint32) {
 	log.Traceln("onSoftbutton", buttons)
-	switch buttons {
-	case softButton_Select:
-		if buttonCallback != nil {
-			buttonCallback()
-		}
-	case softButton_Up:
-		decrementLine()
-	case softButton_Down:
-		incrementLine()
-
+	mu.Lock()
+	d, ok := devices[hdevice]
+	if !ok {
+		d = primaryDevice()
 	}
-	return S_OK
+	record(RecordedEvent{Type: EventButtons, Serial: d.serial, Buttons: buttons})
+	touchDevice(d)
+	run := handleButtons(d, buttons)
+	mu.Unlock()
+
+	// Application actions run unlocked so they may safely use this package
+	runActions(run)
 }
@@ -0,0 +1,104 @@
+package mfd
+
+import (
+	"slices"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Carousel configures turning the pages of the devices on their own
+type Carousel struct {
+	// Dwell is how long each page is shown
+	Dwell time.Duration `json:"dwell"`
+	// Pause is how long the pages stay put after the page wheel or the soft buttons were used
+	Pause time.Duration `json:"pause"`
+	// Pages are the keys of the pages to rotate through, all pages when empty
+	Pages []string `json:"pages,omitempty"`
+}
+
+// The active carousel configuration, nil while disabled
+var carousel *Carousel
+
+// Closed to stop the carousel timer, nil while it is not running
+var carouselStop chan struct{}
+
+// SetCarousel starts rotating through the pages of every device that can be told which page to show.
+// A device with a pinned page keeps it.
+func SetCarousel(c Carousel) {
+	if c.Dwell <= 0 {
+		c.Dwell = 10 * time.Second
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	stopCarousel()
+	carousel = &c
+	carouselStop = make(chan struct{})
+	go rotateCarousel(c.Dwell, carouselStop)
+}
+
+// stopCarousel stops rotating the pages. Must be called with the lock held.
+func stopCarousel() {
+	if carouselStop != nil {
+		close(carouselStop)
+		carouselStop = nil
+	}
+	carousel = nil
+}
+
+func rotateCarousel(dwell time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(dwell)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mu.Lock()
+			if len(deviceOrder) == 0 {
+				turnCarousel(virtualDevice)
+			}
+			for _, handle := range deviceOrder {
+				turnCarousel(devices[handle])
+			}
+			mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// touchDevice records that the page wheel or the soft buttons of a device were used, pausing the carousel.
+// Must be called with the lock held.
+func touchDevice(d *deviceState) {
+	d.touched = time.Now()
+}
+
+// turnCarousel shows the next carousel page on a device, unless it was used recently, has a pinned page,
+// is showing a page of another plugin or can't be told which page to show. Must be called with the lock held.
+func turnCarousel(d *deviceState) {
+	if carousel == nil || d.pinned != "" || (d.handle > 0 && !d.pageActive) || pagesLocked(d) ||
+		time.Since(d.touched) < carousel.Pause {
+		return
+	}
+	next := -1
+	for i := 1; i < len(d.pages); i++ {
+		p := (int(d.currentPage) + i) % len(d.pages)
+		if len(carousel.Pages) == 0 || slices.Contains(carousel.Pages, d.pages[p]) {
+			next = p
+			break
+		}
+	}
+	if next < 0 {
+		return
+	}
+	key := d.pages[next]
+	log.Debugln("Carousel shows page", key)
+	if d.loaded && d.handle > 0 {
+		pb := backend.(PageBackend)
+		if err := pb.SetPage(d.handle, d.pageIDs[key]); err != nil {
+			log.Warnln("Unable to show page", key, err)
+			return
+		}
+	}
+	record(RecordedEvent{Type: EventPage, Serial: d.serial, Page: key, Active: true})
+	showPage(d, next, true)
+}
@@ -0,0 +1,112 @@
+package mfd
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func carouselDisplay() Display {
+	return Display{Pages: []Page{
+		{Key: "first", Lines: []string{"First"}},
+		{Key: "second", Lines: []string{"Second"}},
+		{Key: "third", Lines: []string{"Third"}},
+	}}
+}
+
+// initCarouselSerial attaches a serial display with the carousel pages, which can be told the page to show.
+// It returns the end of the display that sends, and the messages the display receives.
+func initCarouselSerial(t *testing.T) (io.Writer, <-chan string) {
+	t.Helper()
+	hostIn, display := io.Pipe()
+	displayIn, host := io.Pipe()
+	messages := readDisplay(displayIn)
+	s := NewSerial(serialPipe{hostIn, host}, "lcd", Geometry{Width: 20, Lines: 4})
+	if err := InitDevice(s, []string{"first", "second", "third"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		display.Close()
+		DeInitDevice()
+	})
+	return display, messages
+}
+
+func turnTestCarousel() {
+	mu.Lock()
+	defer mu.Unlock()
+	turnCarousel(devices[SerialDevice])
+}
+
+func pageKey(handle uintptr) string {
+	mu.Lock()
+	defer mu.Unlock()
+	return devices[handle].pageKey()
+}
+
+func TestCarousel(t *testing.T) {
+	_, messages := initCarouselSerial(t)
+	// A dwell this long never fires, the test turns the pages by hand
+	SetCarousel(Carousel{Dwell: time.Hour, Pages: []string{"first", "third"}})
+	UpdateDisplay(carouselDisplay())
+	expectMessages(t, messages, "PAGE 1 3")
+
+	for _, want := range []string{"PAGE 3 3", "PAGE 1 3", "PAGE 3 3"} {
+		turnTestCarousel()
+		expectMessages(t, messages, want)
+	}
+	if got := pageKey(SerialDevice); got != "third" {
+		t.Errorf("showing %q, wanted %q", got, "third")
+	}
+}
+
+func TestCarouselKeepsLockedPages(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, []string{"first", "second", "third"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	SetCarousel(Carousel{Dwell: time.Hour})
+	UpdateDisplay(carouselDisplay())
+
+	// The X52 Pro can't be told which page to show, so the carousel leaves it alone
+	mu.Lock()
+	turnCarousel(devices[FakeDevice])
+	mu.Unlock()
+	if got := pageKey(FakeDevice); got != "first" {
+		t.Errorf("showing %q on a device that can't be told the page, wanted %q", got, "first")
+	}
+}
+
+func TestCarouselPausesAfterUse(t *testing.T) {
+	display, messages := initCarouselSerial(t)
+	SetCarousel(Carousel{Dwell: time.Hour, Pause: time.Hour})
+	UpdateDisplay(carouselDisplay())
+
+	io.WriteString(display, "NEXT\n")
+	expectMessages(t, messages, "PAGE 2 3")
+	turnTestCarousel()
+	if got := pageKey(SerialDevice); got != "second" {
+		t.Errorf("showing %q after turning the page on the display, wanted %q", got, "second")
+	}
+
+	mu.Lock()
+	devices[SerialDevice].touched = time.Time{}
+	mu.Unlock()
+	turnTestCarousel()
+	expectMessages(t, messages, "PAGE 3 3")
+}
+
+func TestCarouselKeepsPinnedPage(t *testing.T) {
+	initCarouselSerial(t)
+	SetCarousel(Carousel{Dwell: time.Hour})
+	UpdateDisplay(carouselDisplay())
+
+	mu.Lock()
+	togglePin(devices[SerialDevice])
+	mu.Unlock()
+	turnTestCarousel()
+	if got := pageKey(SerialDevice); got != "first" {
+		t.Errorf("showing %q, wanted the pinned page", got)
+	}
+}
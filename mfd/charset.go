@@ -0,0 +1,118 @@
+package mfd
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ASCII replacements for characters that don't decompose into an ASCII letter and accents
+var transliterations = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'Æ': "AE",
+	'œ': "oe",
+	'Œ': "OE",
+	'ø': "o",
+	'Ø': "O",
+	'ł': "l",
+	'Ł': "L",
+	'đ': "d",
+	'Đ': "D",
+	'ð': "d",
+	'Ð': "D",
+	'þ': "th",
+	'Þ': "Th",
+	'ı': "i",
+	'‘': "'",
+	'’': "'",
+	'‚': ",",
+	'“': "\"",
+	'”': "\"",
+	'„': "\"",
+	'«': "<<",
+	'»': ">>",
+	'–': "-",
+	'—': "-",
+	'…': "...",
+	'•': "*",
+	'·': ".",
+	'×': "x",
+	'÷': "/",
+	'µ': "u",
+	'°': "deg",
+	'→': "->",
+	'←': "<-",
+	'⇒': "=>",
+	'⇐': "<=",
+	'↑': "^",
+	'↓': "v",
+	'█': "#",
+	'▓': "#",
+	'▒': "#",
+	'░': "#",
+	'■': "#",
+}
+
+// Transliterate returns the text as the MFD shows it. Only printable ASCII is sent to the MFD, so accented
+// letters lose their accents and other characters are replaced by a look-alike or a question mark.
+func Transliterate(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		b.WriteString(transliterateRune(r))
+	}
+	return b.String()
+}
+
+func transliterateRune(r rune) string {
+	if s, ok := transliterations[r]; ok {
+		return s
+	}
+	if r >= ' ' && r < 0x7F {
+		return string(r)
+	}
+	// Drop the accents of letters that decompose into an ASCII letter and combining marks
+	var base []rune
+	for _, d := range norm.NFD.String(string(r)) {
+		if !unicode.Is(unicode.Mn, d) {
+			base = append(base, d)
+		}
+	}
+	if len(base) == 1 && base[0] >= ' ' && base[0] < 0x7F {
+		return string(base)
+	}
+	if unicode.IsSpace(r) {
+		return " "
+	}
+	return "?"
+}
+
+// DisplayWidth returns the number of characters a text takes up on the MFD
+func DisplayWidth(text string) int {
+	return utf8.RuneCountInString(Transliterate(text))
+}
+
+// SpaceBetween puts left and right at either end of a line width characters wide. The characters are
+// counted as the MFD shows them, so names with accents or symbols keep the right column aligned.
+// When they don't fit, they are joined without a space.
+func SpaceBetween(width int, left, right string) string {
+	gap := width - DisplayWidth(left) - DisplayWidth(right)
+	return left + strings.Repeat(" ", max(gap, 0)) + right
+}
+
+// Center centers text in a line width characters wide, counting the characters as the MFD shows them
+func Center(width int, text string) string {
+	return FillAround(width, " ", text)
+}
+
+// FillAround centers text in a line width characters wide and fills either side with the fill character,
+// counting the characters as the MFD shows them
+func FillAround(width int, fill, text string) string {
+	n := width - DisplayWidth(text)
+	if n <= 0 {
+		return text
+	}
+	return strings.Repeat(fill, n/2) + text + strings.Repeat(fill, n-n/2)
+}
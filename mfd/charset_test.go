@@ -0,0 +1,74 @@
+package mfd
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{"Jameson Memorial", "Jameson Memorial"},
+		{"Hénon Ørbital", "Henon Orbital"},
+		{"Straße", "Strasse"},
+		{"Dvořák Łódź", "Dvorak Lodz"},
+		{"Königsberg", "Konigsberg"},
+		{"ÄÖÜ", "AOU"},
+		{"“Quote” – dash…", "\"Quote\" - dash..."},
+		{"HDG 270°", "HDG 270deg"},
+		{"A → B ⇐ C", "A -> B <= C"},
+		{"↑↓", "^v"},
+		{"▓▒", "##"},
+		{"back\\slash~", "back\\slash~"},
+		{"tab\there", "tab here"},
+		{"日本", "??"},
+	} {
+		if got := Transliterate(tt.in); got != tt.want {
+			t.Errorf("Transliterate(%q) = %q, wanted %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int
+	}{
+		{"CARGO", 5},
+		{"Straße", 7},
+		{"270°", 6},
+		{"Hénon", 5},
+		{"…", 3},
+	} {
+		if got := DisplayWidth(tt.in); got != tt.want {
+			t.Errorf("DisplayWidth(%q) = %d, wanted %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPaddingCountsDisplayWidth(t *testing.T) {
+	for _, tt := range []struct {
+		got, want string
+	}{
+		{SpaceBetween(16, "Kühlmittel", "12"), "Kühlmittel    12"},
+		{SpaceBetween(16, "Temp", "270°C"), "Temp     270°C"},
+		{SpaceBetween(8, "Straße", "100"), "Straße100"},
+		{Center(10, "Señor"), "  Señor   "},
+		{FillAround(12, "*", " Ü "), "**** Ü *****"},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("got %q, wanted %q", tt.got, tt.want)
+		}
+	}
+}
+
+func TestFrameIsTransliterated(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	display := testDisplay()
+	display.Pages[0].Lines[0] = "Hénon Ørbital"
+	UpdateDisplay(display)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Henon Orbital" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+// Command mfdreplay replays a display recording in the terminal simulator of the MFD.
+// Recordings are made with the recording option in conf.yaml.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+
+	"github.com/pellux-network/EDx52display/mfd"
+)
+
+func main() {
+	var logLevelArg, logFile, webAddress string
+	var speed float64
+	var width, lines int
+	flag.StringVar(&logLevelArg, "log", "info", "Desired log level. One of [panic, fatal, error, warning, info, debug, trace].")
+	flag.StringVar(&logFile, "logfile", "mfdreplay.log", "File to write the log to, as the terminal is used by the simulator.")
+	flag.Float64Var(&speed, "speed", 1, "Replay speed. 2 replays twice as fast, 0 shows the end result straight away. Soft button presses keep their timing, so gestures replay as recorded.")
+	flag.IntVar(&width, "width", mfd.X52Pro.Width, "Number of characters on a line of the simulated display.")
+	flag.IntVar(&lines, "lines", mfd.X52Pro.Lines, "Number of lines of the simulated display.")
+	flag.StringVar(&webAddress, "web", "", "Address to also serve the browser based virtual MFD on, for example localhost:8052.")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] recording.jsonl\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := replay(flag.Arg(0), logLevelArg, logFile, webAddress, speed, mfd.Geometry{Width: width, Lines: lines}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// replay shows a recording until it fails or the user quits
+func replay(path, logLevelArg, logFile, webAddress string, speed float64, g mfd.Geometry) error {
+	recording, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer recording.Close()
+
+	logLevel, err := log.ParseLevel(logLevelArg)
+	if err != nil {
+		return err
+	}
+	log.SetLevel(logLevel)
+	f, err := os.Create(logFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	log.SetOutput(f)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, oldState)
+	}
+
+	if webAddress != "" {
+		webServer, err := mfd.StartWebServer(webAddress)
+		if err != nil {
+			log.Warnln("Unable to start virtual MFD web server:", err)
+		} else {
+			defer webServer.Close()
+		}
+	}
+
+	sim := mfd.NewTerminalSize(os.Stdin, os.Stdout, g)
+	replayed := make(chan error, 1)
+	go func() {
+		replayed <- mfd.Replay(recording, sim, speed)
+	}()
+	defer mfd.DeInitDevice()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	for {
+		select {
+		case err := <-replayed:
+			if err != nil {
+				return err
+			}
+			log.Infoln("Replay finished")
+		case <-sim.Done():
+			return nil
+		case <-interrupt:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Command mfdsim runs the journal reader against a simulated MFD in the terminal.
+// Run it from the app directory so conf.yaml and the names folder are found.
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+
+	"github.com/pellux-network/EDx52display/conf"
+	"github.com/pellux-network/EDx52display/edreader"
+	"github.com/pellux-network/EDx52display/mfd"
+)
+
+func main() {
+	var logLevelArg, logFile string
+	var width, lines int
+	flag.StringVar(&logLevelArg, "log", "info", "Desired log level. One of [panic, fatal, error, warning, info, debug, trace].")
+	flag.StringVar(&logFile, "logfile", "mfdsim.log", "File to write the log to, as the terminal is used by the simulator.")
+	flag.IntVar(&width, "width", mfd.X52Pro.Width, "Number of characters on a line of the simulated display.")
+	flag.IntVar(&lines, "lines", mfd.X52Pro.Lines, "Number of lines of the simulated display.")
+	flag.Parse()
+
+	logLevel, err := log.ParseLevel(logLevelArg)
+	if err != nil {
+		log.Panic(err)
+	}
+	log.SetLevel(logLevel)
+	f, err := os.Create(logFile)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer f.Close()
+	log.SetOutput(f)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer term.Restore(fd, oldState)
+	}
+
+	cfg := conf.LoadConf()
+	sim := mfd.NewTerminalSize(os.Stdin, os.Stdout, mfd.Geometry{Width: width, Lines: lines})
+	if err := mfd.InitDevice(sim, edreader.EnabledPages(cfg), func() {}); err != nil {
+		log.Panic(err)
+	}
+	defer mfd.DeInitDevice()
+
+	stopDisplay := edreader.StartDisplay(cfg)
+	defer stopDisplay()
+
+	edreader.Start(cfg)
+	defer edreader.Stop()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	select {
+	case <-sim.Done():
+	case <-interrupt:
+	}
+}
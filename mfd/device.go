@@ -2,18 +2,41 @@ package mfd
 
 import (
 	"fmt"
+	"image"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// The current device handle
-var device uintptr = 0
+// Guards the device state below, which is changed from driver callbacks, the journal reader and the web server
+var mu sync.Mutex
 
-// The number of pages the device has been initialized with
-var devicePages uint32 = 0
+// The backend driving the display
+var backend Backend
 
-// Whether or not the device has been loaded yet
-var loaded = false
+// The keys of all pages that may be shown, in order
+var pageNames []string
+
+// The index of each page in the current display, keyed by page key
+var pageIndex = map[string]int{}
+
+// The page names assigned to devices, keyed by serial number or handle
+var assignments = map[string][]string{}
+
+// The attached devices, keyed by handle
+var devices = map[uintptr]*deviceState{}
+
+// The handles of the attached devices in the order they were found
+var deviceOrder []uintptr
+
+// The state of unplugged devices, keyed by serial number, so it can be restored when they are plugged in again
+var detached = map[string]*deviceState{}
+
+// The state shown to listeners while no device is attached
+var virtualDevice = newDeviceState(0, "", nil)
 
 // User-defined callback function for the soft button click
 var buttonCallback func()
@@ -21,113 +44,652 @@ var buttonCallback func()
 // The current text content to display
 var currentDisplay Display
 
-// The currently displayed page
-var currentPage uint32
+// Functions notified with the visible frame on every refresh, keyed by the order they were added in
+var (
+	listeners    = map[int]func(Frame){}
+	nextListener int
+)
+
+// deviceState holds the pages and scroll positions of a single device
+type deviceState struct {
+	// The device handle, 0 for the virtual device
+	handle uintptr
+	// The device serial number
+	serial string
+	// Whether the device shows rendered images instead of text lines
+	image bool
+	// The size of the display
+	geometry Geometry
+	// The page keys assigned to this device, nil to show all pages
+	assigned []string
+	// The keys of the pages shown on this device, in order
+	pages []string
+	// The backend page ID of each page key. IDs stay the same while a page comes and goes.
+	pageIDs    map[string]uint32
+	nextPageID uint32
+	// Whether or not the device has been loaded yet
+	loaded bool
+	// The index of the currently displayed page
+	currentPage uint32
+	// Whether or not the current page is active
+	pageActive bool
+	// The line index for each view, keyed by view key
+	currentLines map[string]uint32
+	// The lines drilled into to reach the current child view of each page, keyed by page key
+	paths map[string][]int
+	// The number of marquee steps since the view last changed
+	marqueeTick int
+	// Whether the detailed lines of the pages are shown
+	detail bool
+	// The key of the page pinned to the device and its last content
+	pinned     string
+	pinnedPage Page
+	// The state of the soft buttons
+	gestures gestureState
+	// The lines and images last written to each page of the device
+	shownLines  map[uint32]map[uint32]string
+	shownImages map[uint32]*image.RGBA
+	// The time of the last write and the timer of a pending write
+	lastWrite  time.Time
+	writeTimer *time.Timer
+	// The error that left the device degraded, nil while the device works
+	degraded error
+	// The number of times the device was written again after failing since it last worked,
+	// and whether the write timer is such a retry
+	retries  int
+	retrying bool
+	// The last time the page wheel or the soft buttons were used
+	touched time.Time
+}
+
+// Frame is a snapshot of what the display currently shows
+type Frame struct {
+	PageCount   int      `json:"pageCount"`
+	CurrentPage uint32   `json:"page"`
+	Key         string   `json:"key,omitempty"`
+	Line        uint32   `json:"line"`
+	Lines       []string `json:"lines"`
+	Width       int      `json:"width"`
+	// PagesLocked is set when the page can only be turned on the device itself, such as the X52 Pro
+	PagesLocked bool `json:"pagesLocked,omitempty"`
+}
 
-// Whether or not the current page is active
-var pageActive bool
+// AddListener registers a function that is called with the visible frame every time the display is refreshed.
+// Listeners follow the first attached device, or all pages while no device is attached.
+// The function is called with the device state locked and must not call back into this package.
+// The returned function removes the listener again.
+func AddListener(fn func(Frame)) (remove func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	key := nextListener
+	nextListener++
+	listeners[key] = fn
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(listeners, key)
+	}
+}
 
-// The line index for each page
-var currentLines []uint32
+// AssignPages sets the pages shown on specific devices. The keys are device serial numbers or handles,
+// the values the names of the pages to show in order. Devices without an assignment show all pages.
+// Must be called before InitDevice.
+func AssignPages(pages map[string][]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	assignments = pages
+}
 
-// InitDevice sets up the device for use
-func InitDevice(pages uint32, softButtonCallback func()) error {
+// InitDevice sets up the devices for use on the given backend. pages are the keys of all display pages
+// that may be shown, in order. All of them are shown until the first display update.
+func InitDevice(b Backend, pages []string, softButtonCallback func()) error {
 	log.Infoln("Initializing device driver...")
-	if pages < 1 {
-		return fmt.Errorf("pages parameter must be a positive integer")
+	if b == nil {
+		return fmt.Errorf("no display backend provided")
 	}
-	devicePages = pages
-	currentLines = make([]uint32, pages)
-
-	currentDisplay = Display{Pages: make([]Page, pages)}
+	if len(pages) < 1 {
+		return fmt.Errorf("at least one page is required")
+	}
+	mu.Lock()
+	for _, d := range devices {
+		cancelWrite(d)
+		cancelGestures(d)
+	}
+	backend = b
+	pageNames = pages
+	devices = map[uintptr]*deviceState{}
+	deviceOrder = nil
+	detached = map[string]*deviceState{}
+	setDisplay(Display{Pages: make([]Page, len(pages))})
+	virtualDevice = newDeviceState(0, "", nil)
+	syncPages(virtualDevice)
+	layout = X52Pro
+	leds = map[Led]ledState{}
+	updateBlinkTimer()
+	clearOverlays()
 
 	buttonCallback = softButtonCallback
+	mu.Unlock()
 
 	log.Debugln("Initializing driver connection")
-	initialize()
+	if err := backend.Initialize(); err != nil {
+		return fmt.Errorf("unable to initialize display backend: %w", err)
+	}
 	log.Debugln("Registering device callbacks")
-	registerDeviceCallback()
-	log.Debugln("Searching for device")
-	enumerate()
+	if err := backend.RegisterDeviceCallback(onDeviceChanged); err != nil {
+		return fmt.Errorf("unable to register device callback: %w", err)
+	}
+	log.Debugln("Searching for devices")
+	if err := backend.Enumerate(onEnumerate); err != nil {
+		return fmt.Errorf("unable to enumerate devices: %w", err)
+	}
 	return nil
 }
 
 // DeInitDevice unregisters the device driver interaction. Should be called before terminating the program
 func DeInitDevice() {
-	deinitialize()
+	mu.Lock()
+	leds = map[Led]ledState{}
+	updateBlinkTimer()
+	stopMarquee()
+	stopCarousel()
+	for _, d := range devices {
+		cancelWrite(d)
+		cancelGestures(d)
+	}
+	mu.Unlock()
+	if backend == nil {
+		return
+	}
+	if err := backend.Deinitialize(); err != nil {
+		log.Warnln("Error deinitializing display backend:", err)
+	}
 }
 
 // UpdateDisplay updates the displayed text with a new set of pages.
+// When the pages have keys, any of the pages passed to InitDevice may be left out to hide them until a later update.
+// Pages without keys must all be present, in the order passed to InitDevice.
+// The display is still updated when a device is degraded, and a *DeviceError is returned for each degraded device.
 func UpdateDisplay(display Display) error {
+	mu.Lock()
+	defer mu.Unlock()
+	old := map[string]Page{}
+	for key, idx := range pageIndex {
+		old[key] = currentDisplay.Pages[idx]
+	}
+	if err := setDisplay(display); err != nil {
+		return err
+	}
+	record(RecordedEvent{Type: EventDisplay, Display: &currentDisplay})
+	anchorViews(virtualDevice, old)
+	for _, d := range devices {
+		anchorViews(d, old)
+	}
+	for _, d := range detached {
+		anchorViews(d, old)
+	}
+	if len(deviceOrder) == 0 {
+		syncPages(virtualDevice)
+	}
+	for _, handle := range deviceOrder {
+		syncPages(devices[handle])
+	}
+	refreshAll()
+	return deviceErrors()
+}
 
-	if len(display.Pages) != int(devicePages) {
-		return fmt.Errorf("provided display has %d pages. Must have %d", len(display.Pages), devicePages)
+// setDisplay checks the pages of a display and makes it the current display. Must be called with the lock held.
+func setDisplay(display Display) error {
+	keyed := 0
+	for _, page := range display.Pages {
+		if page.Key != "" {
+			keyed++
+		}
 	}
-	currentDisplay = display
-	refreshDisplay()
+	pages := make([]Page, len(display.Pages))
+	copy(pages, display.Pages)
+	index := map[string]int{}
+	switch keyed {
+	case 0:
+		if len(pages) != len(pageNames) {
+			return fmt.Errorf("provided display has %d pages. Must have %d", len(pages), len(pageNames))
+		}
+		for i := range pages {
+			pages[i].Key = pageNames[i]
+			index[pageNames[i]] = i
+		}
+	case len(pages):
+		for i, page := range pages {
+			if _, ok := index[page.Key]; ok {
+				return fmt.Errorf("provided display has page %q more than once", page.Key)
+			}
+			if !knownPage(page.Key) {
+				return fmt.Errorf("provided display has unknown page %q", page.Key)
+			}
+			index[page.Key] = i
+		}
+	default:
+		return fmt.Errorf("provided display has %d pages without a key", len(pages)-keyed)
+	}
+	currentDisplay = Display{Pages: pages}
+	pageIndex = index
 	return nil
 }
 
-func initPages() {
-	if !loaded {
-		log.Debugln("Device found.")
+// knownPage reports whether a page key was passed to InitDevice. Must be called with the lock held.
+func knownPage(key string) bool {
+	for _, name := range pageNames {
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+func newDeviceState(handle uintptr, serial string, assigned []string) *deviceState {
+	return &deviceState{
+		handle:       handle,
+		serial:       serial,
+		assigned:     assigned,
+		geometry:     X52Pro,
+		pageIDs:      map[string]uint32{},
+		pageActive:   true,
+		currentLines: map[string]uint32{},
+		paths:        map[string][]int{},
+	}
+}
+
+// assignedPages returns the keys of the pages assigned to a device, or nil to show all pages.
+// Must be called with the lock held.
+func assignedPages(handle uintptr, serial string) []string {
+	names, ok := assignments[serial]
+	if !ok {
+		names, ok = assignments[fmt.Sprint(handle)]
+	}
+	if !ok {
+		names, ok = assignments[fmt.Sprintf("%#x", handle)]
+	}
+	if !ok {
+		return nil
+	}
+	pages := []string{}
+	for _, name := range names {
+		if knownPage(name) {
+			pages = append(pages, name)
+		} else {
+			log.Warnf("Page %q assigned to device %s is not enabled", name, serial)
+		}
+	}
+	if len(pages) == 0 {
+		log.Warnf("No enabled pages assigned to device %s, showing all pages", serial)
+		return nil
+	}
+	return pages
+}
+
+// wantedPages returns the keys of the pages of the current display a device should show, in order.
+// Must be called with the lock held.
+func wantedPages(d *deviceState) []string {
+	pages := []string{}
+	for _, name := range pageNames {
+		if _, ok := pageIndex[name]; !ok && name != d.pinned {
+			continue
+		}
+		if d.assigned == nil {
+			pages = append(pages, name)
+			continue
+		}
+		for _, assigned := range d.assigned {
+			if assigned == name {
+				pages = append(pages, name)
+				break
+			}
+		}
+	}
+	return pages
+}
+
+// syncPages adds and removes pages on a device so it shows the pages of the current display.
+// The current page and the scroll positions follow the page keys. Must be called with the lock held.
+func syncPages(d *deviceState) {
+	if idx, ok := pageIndex[d.pinned]; ok {
+		d.pinnedPage = currentDisplay.Pages[idx]
+	}
+	trimPaths(d)
+	pages := wantedPages(d)
+	if slices.Equal(pages, d.pages) {
+		return
+	}
+	currentKey := d.pageKey()
+	for _, key := range pages {
+		if _, ok := d.pageIDs[key]; !ok {
+			d.pageIDs[key] = d.nextPageID
+			d.nextPageID++
+		}
+	}
+
+	if d.loaded && d.handle > 0 {
+		// Pages are shown in the order they were added, so every page from the first change on is added again
+		first := 0
+		for first < len(pages) && first < len(d.pages) && pages[first] == d.pages[first] {
+			first++
+		}
+		removePages(d, d.pages[first:])
+		activeKey := ""
+		if d.pageActive {
+			activeKey = currentKey
+		}
+		addPages(d, pages[first:], activeKey)
+	}
+
+	d.pages = pages
+	current := slices.Index(pages, currentKey)
+	if current < 0 {
+		// The current page is gone, show the one that took its place
+		current = max(min(int(d.currentPage), len(pages)-1), 0)
+		d.marqueeTick = 0
+	}
+	d.currentPage = uint32(current)
+	if d.loaded && d.handle > 0 {
+		applyLeds(d)
+	}
+}
+
+// removePages removes pages from a device. Must be called with the lock held.
+func removePages(d *deviceState, keys []string) {
+	for _, key := range keys {
+		id := d.pageIDs[key]
+		log.Debugln("Removing page", key)
+		if err := deviceCall(d, func() error { return backend.RemovePage(d.handle, id) }); err != nil {
+			log.Warnln("Unable to remove page", key, err)
+		}
+		delete(d.shownLines, id)
+		delete(d.shownImages, id)
+	}
+}
+
+// addPages adds pages to a device, making the page with the active key the active page.
+// Must be called with the lock held.
+func addPages(d *deviceState, keys []string, activeKey string) {
+	for _, key := range keys {
+		log.Debugln("Adding page", key)
+		active := key == activeKey
+		if err := deviceCall(d, func() error { return backend.AddPage(d.handle, d.pageIDs[key], active) }); err != nil {
+			log.Warnln("Unable to add page", key, err)
+		}
+	}
+}
+
+// attachDevice starts driving a newly found device. When the device was plugged in before,
+// its current page and scroll positions are restored. Must be called with the lock held.
+func attachDevice(handle uintptr) {
+	if _, ok := devices[handle]; ok {
+		return
+	}
+	serial, err := backend.GetSerialNumber(handle)
+	if err != nil {
+		log.Warnln("Unable to read device serial number:", err)
+	}
+	log.Infof("Found device %s (handle %#x)", serial, handle)
+
+	d, ok := detached[serial]
+	if ok && serial != "" {
+		delete(detached, serial)
+		d.handle = handle
+	} else {
+		d = newDeviceState(handle, serial, assignedPages(handle, serial))
+	}
+	syncPages(d)
+	if ib, ok := backend.(ImageBackend); ok {
+		d.image = ib.IsImageDevice(handle)
+	}
+	d.geometry = deviceGeometry(handle)
+	devices[handle] = d
+	deviceOrder = append(deviceOrder, handle)
+	initPages(d)
+	updateLayout()
+}
+
+// detachDevice stops driving a removed device, keeping its state in case it comes back.
+// Must be called with the lock held.
+func detachDevice(handle uintptr) {
+	d, ok := devices[handle]
+	if !ok {
+		return
+	}
+	delete(devices, handle)
+	for i, h := range deviceOrder {
+		if h == handle {
+			deviceOrder = append(deviceOrder[:i], deviceOrder[i+1:]...)
+			break
+		}
+	}
+	cancelWrite(d)
+	cancelGestures(d)
+	d.handle = 0
+	d.loaded = false
+	d.degraded = nil
+	d.retries = 0
+	if d.serial != "" {
+		detached[d.serial] = d
+	}
+	updateLayout()
+	log.Warnf("Device %s was unplugged. Waiting for it to be plugged in again.", d.serial)
+}
+
+// primaryDevice returns the device followed by listeners. Must be called with the lock held.
+func primaryDevice() *deviceState {
+	if len(deviceOrder) > 0 {
+		return devices[deviceOrder[0]]
+	}
+	return virtualDevice
+}
+
+// initPages registers the callbacks and pages on a device. Must be called with the lock held.
+func initPages(d *deviceState) {
+	if !d.loaded {
+		if d.currentPage >= uint32(len(d.pages)) {
+			d.currentPage = 0
+		}
 		log.Debugln("Setting up page button callback")
-		registerPageCallback(device)
+		if err := backend.RegisterPageCallback(d.handle, onPageChange); err != nil {
+			log.Warnln("Unable to register page callback:", err)
+		}
 		log.Debugln("Setting up scroll button callback")
-		registerSoftButtonCallback(device)
+		if err := backend.RegisterSoftButtonCallback(d.handle, onSoftButton); err != nil {
+			log.Warnln("Unable to register soft button callback:", err)
+		}
+		// The pages are new, so nothing is shown on them yet
+		forgetWrites(d)
 		log.Debugln("Adding pages...")
-		for p := uint32(0); p < devicePages; p++ {
-			addPage(p, p == 0)
+		for p, key := range d.pages {
+			active := uint32(p) == d.currentPage
+			if err := deviceCall(d, func() error { return backend.AddPage(d.handle, d.pageIDs[key], active) }); err != nil {
+				log.Warnln("Unable to add page", key, err)
+			}
 		}
-		pageActive = true
-		refreshDisplay()
-		loaded = true
+		d.pageActive = true
+		d.loaded = true
+		refreshDisplay(d)
+		applyLeds(d)
 		log.Debugln("Device init complete")
 	}
 }
 
-func incrementLine() {
-	page := currentDisplay.Pages[currentPage]
-	line := currentLines[currentPage]
-	pageLines := uint32(len(page.Lines))
-	currentLines[currentPage] = min(line+1, pageLines)
-	refreshDisplay()
+func incrementLine(d *deviceState) {
+	if d.pageKey() == "" {
+		return
+	}
+	key := d.viewKey()
+	line := d.currentLines[key]
+	pageLines := uint32(len(d.page().Lines))
+	d.currentLines[key] = min(line+1, pageLines)
+	d.marqueeTick = 0
+	refreshDisplay(d)
 }
 
-func decrementLine() {
-	line := currentLines[currentPage]
-	if line > 0 {
-		currentLines[currentPage] = line - 1
+func decrementLine(d *deviceState) {
+	key := d.viewKey()
+	if line := d.currentLines[key]; line > 0 {
+		d.currentLines[key] = line - 1
 	}
-	refreshDisplay()
+	d.marqueeTick = 0
+	refreshDisplay(d)
 }
 
-// refreshDisplay refreshes the display to show the current values for page, line and display variables
-func refreshDisplay() {
-	if loaded && device > 0 && pageActive {
-		log.Debugln("Refreshing display")
-		page := currentDisplay.Pages[currentPage]
-		line := currentLines[currentPage]
-
-		if line >= uint32(len(page.Lines)) {
-			line = uint32(len(page.Lines)) - 1
+// turnPage moves the current page by delta, wrapping around at either end. The device is told which page
+// to show, and a device that can't be told keeps its page, so the app never shows another page than the device.
+// Must be called with the lock held.
+func turnPage(d *deviceState, delta int) {
+	pages := len(d.pages)
+	if pages == 0 {
+		return
+	}
+	if pagesLocked(d) {
+		log.Debugln("The pages of device", d.serial, "can only be turned on the device")
+		return
+	}
+	if d.loaded && d.handle > 0 {
+		key := d.pages[(int(d.currentPage)+delta%pages+pages)%pages]
+		pb := backend.(PageBackend)
+		if err := pb.SetPage(d.handle, d.pageIDs[key]); err != nil {
+			log.Warnln("Unable to show page", key, err)
+			return
 		}
+		record(RecordedEvent{Type: EventPage, Serial: d.serial, Page: key, Active: true})
+	}
+	d.currentPage = uint32((int(d.currentPage) + delta%pages + pages) % pages)
+	d.pageActive = true
+	d.marqueeTick = 0
+	refreshDisplay(d)
+}
 
-		for l := uint32(0); l < 3; l++ {
-			shiftedLine := int(line + l)
-			text := ""
-			if shiftedLine < len(page.Lines) {
-				text = page.Lines[shiftedLine]
-			}
-			setString(currentPage, l, text)
+// pagesLocked returns whether the page shown can't be changed from the app, because the backend
+// has no way to tell the device. Must be called with the lock held.
+func pagesLocked(d *deviceState) bool {
+	if !d.loaded || d.handle == 0 {
+		return false
+	}
+	_, ok := backend.(PageBackend)
+	return !ok
+}
+
+// pageKey returns the key of the page currently shown on the device, or an empty string without pages
+func (d *deviceState) pageKey() string {
+	if int(d.currentPage) >= len(d.pages) {
+		return ""
+	}
+	return d.pages[d.currentPage]
+}
+
+// pageID returns the backend page ID of the page currently shown on the device
+func (d *deviceState) pageID() uint32 {
+	return d.pageIDs[d.pageKey()]
+}
+
+// viewKey returns the key of the view currently shown on the device. Child views add the
+// selected line of each level to the page key, so each level keeps its own scroll position.
+func (d *deviceState) viewKey() string {
+	key := d.pageKey()
+	for _, line := range d.paths[key] {
+		key += "/" + strconv.Itoa(line)
+	}
+	return key
+}
+
+// view returns the page or child view currently shown on the device
+func (d *deviceState) view() Page {
+	page := d.displayPage(d.pageKey())
+	for _, line := range d.paths[d.pageKey()] {
+		page = page.Children[line]
+	}
+	return page
+}
+
+// page returns the view currently shown on the device, with the detailed lines in the detail view
+func (d *deviceState) page() Page {
+	page := d.view()
+	if d.detail && len(page.Detail) > 0 {
+		// The rows describe the lines, not the details
+		page.Lines = page.Detail
+		page.Rows = nil
+	}
+	return page
+}
+
+// displayPage returns a page of the current display, or the last content of the pinned page
+func (d *deviceState) displayPage(key string) Page {
+	if idx, ok := pageIndex[key]; ok {
+		return currentDisplay.Pages[idx]
+	}
+	if key != "" && key == d.pinned {
+		return d.pinnedPage
+	}
+	return Page{}
+}
+
+// frame returns the lines visible on the current page of the device
+func (d *deviceState) frame() Frame {
+	frame := Frame{PageCount: len(d.pages), CurrentPage: d.currentPage, Width: d.geometry.Width, PagesLocked: pagesLocked(d)}
+	if int(d.currentPage) >= len(d.pages) {
+		return frame
+	}
+	frame.Key = d.pageKey()
+	page := d.page()
+	line := d.visibleLine()
+	frame.Line = line
+	scroll := marqueeApplies(d)
+	if o := overlayFor(d.pageKey()); o != nil {
+		// The overlay hides the page but keeps its scroll position
+		page = Page{Lines: o.Lines}
+		line = 0
+	}
+
+	for l := uint32(0); l < uint32(d.geometry.Lines); l++ {
+		shiftedLine := int(line + l)
+		text := ""
+		if shiftedLine < len(page.Lines) {
+			text = Transliterate(page.Lines[shiftedLine])
 		}
+		if scroll {
+			text = marqueeText(text, d.marqueeTick, d.geometry.Width)
+		}
+		frame.Lines = append(frame.Lines, text)
 	}
+	return frame
+}
 
+// visibleLine returns the first visible line of the current page, clamped to the page length
+func (d *deviceState) visibleLine() uint32 {
+	page := d.page()
+	line := d.currentLines[d.viewKey()]
+	if line >= uint32(len(page.Lines)) && len(page.Lines) > 0 {
+		line = uint32(len(page.Lines)) - 1
+	}
+	return line
+}
+
+// refreshAll refreshes every device. Must be called with the lock held.
+func refreshAll() {
+	if len(deviceOrder) == 0 {
+		refreshDisplay(virtualDevice)
+	}
+	for _, handle := range deviceOrder {
+		refreshDisplay(devices[handle])
+	}
 }
 
-func min(a, b uint32) uint32 {
-	if a < b {
-		return a
+// refreshDisplay refreshes a device to show the current values for page, line and display variables.
+// Must be called with the lock held.
+func refreshDisplay(d *deviceState) {
+	frame := d.frame()
+	if d == primaryDevice() {
+		for _, fn := range listeners {
+			fn(frame)
+		}
+	}
+
+	if d.loaded && d.handle > 0 && d.pageActive {
+		scheduleWrite(d)
 	}
-	return b
 }
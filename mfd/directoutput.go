@@ -1,20 +1,27 @@
+//go:build windows
+
 package mfd
 
 import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
 	"syscall"
 	"unsafe"
 
 	log "github.com/sirupsen/logrus"
 )
 
-const (
-	// HRESULT codes
-	S_OK             = 0x00000000
-	E_PAGENOTACTIVE  = 0xFF040001
-	E_BUFFERTOOSMALL = 0xFF040000 | uintptr(syscall.ERROR_BUFFER_OVERFLOW)
+const FLAG_SET_AS_ACTIVE = 0x00000001
 
-	FLAG_SET_AS_ACTIVE = 0x00000001
-)
+// The device type GUID of the Saitek Flight Instrument Panel, {3E083CD8-6A37-4A58-80A8-3D6A2C07513E}
+var deviceTypeFIP = syscall.GUID{
+	Data1: 0x3E083CD8,
+	Data2: 0x6A37,
+	Data3: 0x4A58,
+	Data4: [8]byte{0x80, 0xA8, 0x3D, 0x6A, 0x2C, 0x07, 0x51, 0x3E},
+}
 
 const (
 	dllPath    = "./bin/DirectOutput.dll"
@@ -22,62 +29,230 @@ const (
 	context    = 0xCAFEBABE
 )
 
-var directOutput = syscall.NewLazyDLL(dllPath)
+// DirectOutput is the Backend talking to the Saitek DirectOutput driver
+type DirectOutput struct {
+	dll *syscall.LazyDLL
+
+	// The callbacks handed to the driver. They are created once, as the runtime can only create
+	// a limited number of callbacks and never frees them, and look up the functions registered below.
+	enumerateCallback  uintptr
+	deviceCallback     uintptr
+	pageCallback       uintptr
+	softButtonCallback uintptr
+
+	// Held while enumerating, so the enumerate function belongs to the running enumeration
+	enumerateMu sync.Mutex
+
+	mu           sync.Mutex
+	enumerate    func(device uintptr)
+	onDevice     DeviceChangeFunc
+	onPage       map[uintptr]PageChangeFunc
+	onSoftButton map[uintptr]SoftButtonFunc
+}
+
+// NewDirectOutput returns a Backend using the bundled DirectOutput.dll
+func NewDirectOutput() *DirectOutput {
+	d := &DirectOutput{
+		dll:          syscall.NewLazyDLL(dllPath),
+		onPage:       map[uintptr]PageChangeFunc{},
+		onSoftButton: map[uintptr]SoftButtonFunc{},
+	}
+	d.enumerateCallback = syscall.NewCallback(d.enumerated)
+	d.deviceCallback = syscall.NewCallback(d.deviceChanged)
+	d.pageCallback = syscall.NewCallback(d.pageChanged)
+	d.softButtonCallback = syscall.NewCallback(d.softButtonChanged)
+	return d
+}
 
-func initialize() {
+// Initialize implements Backend
+func (d *DirectOutput) Initialize() error {
 	pluginNamePtr, _ := syscall.UTF16PtrFromString(pluginName)
-	callProc("DirectOutput_Initialize", uintptr(unsafe.Pointer(pluginNamePtr)))
+	return d.callProc("DirectOutput_Initialize", uintptr(unsafe.Pointer(pluginNamePtr)))
+}
+
+// Deinitialize implements Backend
+func (d *DirectOutput) Deinitialize() error {
+	return d.callProc("DirectOutput_Deinitialize")
+}
+
+// Enumerate implements Backend
+func (d *DirectOutput) Enumerate(fn func(device uintptr)) error {
+	d.enumerateMu.Lock()
+	defer d.enumerateMu.Unlock()
+	d.mu.Lock()
+	d.enumerate = fn
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.enumerate = nil
+		d.mu.Unlock()
+	}()
+	return d.callProc("DirectOutput_Enumerate", d.enumerateCallback, context)
+}
+
+// GetSerialNumber implements Backend
+func (d *DirectOutput) GetSerialNumber(device uintptr) (string, error) {
+	// Serial numbers are short, but the driver says so when one doesn't fit
+	for size := 64; ; size *= 4 {
+		buf := make([]uint16, size)
+		err := d.callProc("DirectOutput_GetSerialNumber", device, uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+		if errors.Is(err, ErrBufferTooSmall) && size < 4096 {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return syscall.UTF16ToString(buf), nil
+	}
+}
+
+// RegisterDeviceCallback implements Backend
+func (d *DirectOutput) RegisterDeviceCallback(fn DeviceChangeFunc) error {
+	d.mu.Lock()
+	d.onDevice = fn
+	d.mu.Unlock()
+	return d.callProc("DirectOutput_RegisterDeviceCallback", d.deviceCallback, context)
 }
 
-func deinitialize() {
-	callProc("DirectOutput_Deinitialize")
+// RegisterPageCallback implements Backend
+func (d *DirectOutput) RegisterPageCallback(device uintptr, fn PageChangeFunc) error {
+	d.mu.Lock()
+	d.onPage[device] = fn
+	d.mu.Unlock()
+	return d.callProc("DirectOutput_RegisterPageCallback", device, d.pageCallback, context)
 }
 
-func enumerate() {
-	callback := syscall.NewCallback(onEnumerate)
-	callProc("DirectOutput_Enumerate", callback, context)
+// RegisterSoftButtonCallback implements Backend
+func (d *DirectOutput) RegisterSoftButtonCallback(device uintptr, fn SoftButtonFunc) error {
+	d.mu.Lock()
+	d.onSoftButton[device] = fn
+	d.mu.Unlock()
+	return d.callProc("DirectOutput_RegisterSoftButtonCallback", device, d.softButtonCallback, context)
 }
 
-func registerDeviceCallback() {
-	callback := syscall.NewCallback(onDeviceChanged)
-	callProc("DirectOutput_RegisterDeviceCallback", callback, context)
+// enumerated is called by the driver for every device while enumerating
+func (d *DirectOutput) enumerated(hdevice uintptr, context uintptr) uintptr {
+	d.mu.Lock()
+	fn := d.enumerate
+	d.mu.Unlock()
+	if fn != nil {
+		fn(hdevice)
+	}
+	return S_OK
+}
+
+// deviceChanged is called by the driver when a device is added or removed.
+// The functions registered for a removed device are dropped, the driver may reuse its handle.
+func (d *DirectOutput) deviceChanged(hdevice uintptr, added bool, context uintptr) uintptr {
+	d.mu.Lock()
+	fn := d.onDevice
+	if !added {
+		delete(d.onPage, hdevice)
+		delete(d.onSoftButton, hdevice)
+	}
+	d.mu.Unlock()
+	if fn != nil {
+		fn(hdevice, added)
+	}
+	return S_OK
 }
 
-func registerPageCallback(device uintptr) {
-	callback := syscall.NewCallback(onPageChange)
-	callProc("DirectOutput_RegisterPageCallback", device, callback, context)
+// pageChanged is called by the driver when the page wheel of a device is turned
+func (d *DirectOutput) pageChanged(hdevice uintptr, page uint32, setActive bool, context uintptr) uintptr {
+	d.mu.Lock()
+	fn := d.onPage[hdevice]
+	d.mu.Unlock()
+	if fn != nil {
+		fn(hdevice, page, setActive)
+	}
+	return S_OK
 }
 
-func registerSoftButtonCallback(device uintptr) {
-	callback := syscall.NewCallback(onSoftButton)
-	callProc("DirectOutput_RegisterSoftButtonCallback", device, callback, context)
+// softButtonChanged is called by the driver when the soft buttons of a device are used
+func (d *DirectOutput) softButtonChanged(hdevice uintptr, buttons uint32, context uintptr) uintptr {
+	d.mu.Lock()
+	fn := d.onSoftButton[hdevice]
+	d.mu.Unlock()
+	if fn != nil {
+		fn(hdevice, buttons)
+	}
+	return S_OK
 }
 
-func addPage(pageNumber uint32, active bool) {
+// AddPage implements Backend
+func (d *DirectOutput) AddPage(device uintptr, page uint32, active bool) error {
 	var flag uintptr = 0
 	if active {
 		flag = uintptr(FLAG_SET_AS_ACTIVE)
 	}
-	callProc("DirectOutput_AddPage", device, uintptr(pageNumber), flag)
+	return d.callProc("DirectOutput_AddPage", device, uintptr(page), flag)
+}
+
+// RemovePage implements Backend
+func (d *DirectOutput) RemovePage(device uintptr, page uint32) error {
+	return d.callProc("DirectOutput_RemovePage", device, uintptr(page))
+}
+
+// SetString implements Backend
+func (d *DirectOutput) SetString(device uintptr, page, line uint32, text string) error {
+	chars, err := syscall.UTF16FromString(text)
+	if err != nil {
+		return err
+	}
+	// The length excludes the terminating null
+	lineLen := uintptr(len(chars) - 1)
+	return d.callProc("DirectOutput_SetString", device, uintptr(page), uintptr(line), lineLen, uintptr(unsafe.Pointer(&chars[0])))
+}
+
+// SetLed implements Backend
+func (d *DirectOutput) SetLed(device uintptr, page, led uint32, on bool) error {
+	var value uintptr = 0
+	if on {
+		value = 1
+	}
+	return d.callProc("DirectOutput_SetLed", device, uintptr(page), uintptr(led), value)
 }
 
-func setString(page, lineIdx uint32, line string) {
-	linePtr, _ := syscall.UTF16PtrFromString(line)
-	lineLen := uintptr(len(line))
-	callProc("DirectOutput_SetString", device, uintptr(page), uintptr(lineIdx), lineLen, uintptr(unsafe.Pointer(linePtr)))
+// IsImageDevice implements ImageBackend
+func (d *DirectOutput) IsImageDevice(device uintptr) bool {
+	var guid syscall.GUID
+	if err := d.callProc("DirectOutput_GetDeviceType", device, uintptr(unsafe.Pointer(&guid))); err != nil {
+		return false
+	}
+	return guid == deviceTypeFIP
 }
 
-func callProc(procname string, args ...uintptr) {
-	proc := directOutput.NewProc(procname)
-	hresult, _, err := proc.Call(args...)
+// SetImage implements ImageBackend
+func (d *DirectOutput) SetImage(device uintptr, page uint32, img image.Image) error {
+	// The FIP takes 24 bit BGR pixels with the rows bottom up
+	const stride = FIPWidth * 3
+	data := make([]byte, stride*FIPHeight)
+	b := img.Bounds()
+	for y := 0; y < FIPHeight; y++ {
+		row := data[(FIPHeight-1-y)*stride:]
+		for x := 0; x < FIPWidth; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			row[x*3] = byte(bl >> 8)
+			row[x*3+1] = byte(g >> 8)
+			row[x*3+2] = byte(r >> 8)
+		}
+	}
+	return d.callProc("DirectOutput_SetImage", device, uintptr(page), 0, uintptr(len(data)), uintptr(unsafe.Pointer(&data[0])))
+}
+
+// callProc calls a driver function. A failure is returned as an *HResultError.
+func (d *DirectOutput) callProc(procname string, args ...uintptr) error {
+	proc := d.dll.NewProc(procname)
+	if err := proc.Find(); err != nil {
+		return fmt.Errorf("unable to load %s: %w", procname, err)
+	}
+	r, _, _ := proc.Call(args...)
 
-	switch hresult {
-	case S_OK:
-		return
-	case E_PAGENOTACTIVE:
-		log.Warnf("hresult %x (E_PAGENOTACTIVE)\n", hresult)
-	default:
-		log.Warnf("hresult %x\n", hresult)
-		log.Fatalln(err)
+	// HRESULTs are 32 bits wide
+	if hresult := uint32(r); hresult != S_OK {
+		err := &HResultError{Proc: procname, Code: hresult}
+		log.Traceln(err)
+		return err
 	}
+	return nil
 }
@@ -0,0 +1,49 @@
+package mfd
+
+import log "github.com/sirupsen/logrus"
+
+// openChild drills into the child view of the first visible line that has one. Must be called with the lock held.
+func openChild(d *deviceState) {
+	key := d.pageKey()
+	view := d.view()
+	if key == "" || (d.detail && len(view.Detail) > 0) {
+		return
+	}
+	start := int(d.visibleLine())
+	for l := start; l < start+d.geometry.Lines && l < len(view.Lines); l++ {
+		if _, ok := view.Children[l]; ok {
+			log.Debugln("Opening child view of line", l, "on page", key)
+			d.paths[key] = append(d.paths[key], l)
+			d.marqueeTick = 0
+			refreshDisplay(d)
+			return
+		}
+	}
+}
+
+// closeChild goes back from a child view to its parent. Must be called with the lock held.
+func closeChild(d *deviceState) {
+	key := d.pageKey()
+	path := d.paths[key]
+	if len(path) == 0 {
+		return
+	}
+	d.paths[key] = path[:len(path)-1]
+	d.marqueeTick = 0
+	refreshDisplay(d)
+}
+
+// trimPaths leaves child views that are gone from the current display. Must be called with the lock held.
+func trimPaths(d *deviceState) {
+	for key, path := range d.paths {
+		page := d.displayPage(key)
+		for i, line := range path {
+			child, ok := page.Children[line]
+			if !ok {
+				d.paths[key] = path[:i]
+				break
+			}
+			page = child
+		}
+	}
+}
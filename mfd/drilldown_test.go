@@ -0,0 +1,51 @@
+package mfd
+
+import "testing"
+
+func TestDrillDown(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	setTestGestures(t, map[string]string{GestureClick: ActionOpen, GestureLongPress: ActionBack, GestureSelectUp: ActionBack})
+
+	first := Page{Key: "first"}
+	for _, line := range []string{"CARGO", "Gold", "Silver", "Tea"} {
+		first.Add("%s", line)
+		if line != "CARGO" {
+			first.AddChild(Page{Lines: []string{line + " details", "Count", "Stolen", "Mission"}})
+		}
+	}
+	display := Display{Pages: []Page{first, {Key: "second", Lines: []string{"Second"}}}}
+	UpdateDisplay(display)
+
+	// Scroll to the second item and drill in, the first visible line with a child view opens
+	fake.PressButtons(FakeDevice, softButton_Down)
+	fake.PressButtons(FakeDevice, softButton_Down)
+	fake.PressButtons(FakeDevice, softButton_Select)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Silver details" {
+		t.Fatalf("got %q after opening, wanted the child view", got)
+	}
+	fake.PressButtons(FakeDevice, softButton_Down)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Count" {
+		t.Errorf("got %q after scrolling the child view", got)
+	}
+
+	// Going back restores the scroll position of the parent
+	fake.PressButtons(FakeDevice, softButton_Select|softButton_Up)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Silver" {
+		t.Errorf("got %q after going back, wanted the parent at its scroll position", got)
+	}
+	fake.PressButtons(FakeDevice, softButton_Select)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Count" {
+		t.Errorf("got %q after opening again, wanted the child at its scroll position", got)
+	}
+
+	// A child view that is gone from the display is left
+	delete(display.Pages[0].Children, 2)
+	UpdateDisplay(display)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Silver" {
+		t.Errorf("got %q after the child view was removed", got)
+	}
+}
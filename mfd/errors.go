@@ -0,0 +1,160 @@
+package mfd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HRESULT codes returned by the DirectOutput driver
+const (
+	S_OK            = 0x00000000
+	E_PAGENOTACTIVE = 0xFF040001
+	// 0xFF040000 | ERROR_BUFFER_OVERFLOW
+	E_BUFFERTOOSMALL = 0xFF04006F
+	E_NOTIMPL        = 0x80004001
+	E_HANDLE         = 0x80070006
+	E_OUTOFMEMORY    = 0x8007000E
+	E_INVALIDARG     = 0x80070057
+)
+
+var hresultNames = map[uint32]string{
+	E_PAGENOTACTIVE:  "E_PAGENOTACTIVE",
+	E_BUFFERTOOSMALL: "E_BUFFERTOOSMALL",
+	E_NOTIMPL:        "E_NOTIMPL",
+	E_HANDLE:         "E_HANDLE",
+	E_OUTOFMEMORY:    "E_OUTOFMEMORY",
+	E_INVALIDARG:     "E_INVALIDARG",
+}
+
+// HResultError is a failed call into the DirectOutput driver
+type HResultError struct {
+	// Proc is the name of the driver function that failed
+	Proc string
+	// Code is the HRESULT the function returned
+	Code uint32
+}
+
+func (e *HResultError) Error() string {
+	name, ok := hresultNames[e.Code]
+	if !ok {
+		name = "unknown error"
+	}
+	if e.Proc == "" {
+		return fmt.Sprintf("hresult %#x (%s)", e.Code, name)
+	}
+	return fmt.Sprintf("%s failed with hresult %#x (%s)", e.Proc, e.Code, name)
+}
+
+// Is matches errors with the same HRESULT, so the errors below match a failure of any driver function
+func (e *HResultError) Is(target error) bool {
+	t, ok := target.(*HResultError)
+	return ok && t.Code == e.Code
+}
+
+// The errors reported by the DirectOutput driver, for use with errors.Is
+var (
+	ErrPageNotActive  = &HResultError{Code: E_PAGENOTACTIVE}
+	ErrBufferTooSmall = &HResultError{Code: E_BUFFERTOOSMALL}
+	ErrNotImplemented = &HResultError{Code: E_NOTIMPL}
+	ErrInvalidHandle  = &HResultError{Code: E_HANDLE}
+	ErrOutOfMemory    = &HResultError{Code: E_OUTOFMEMORY}
+	ErrInvalidArg     = &HResultError{Code: E_INVALIDARG}
+)
+
+// DeviceError is the error that left a device degraded
+type DeviceError struct {
+	// Serial is the serial number of the device
+	Serial string
+	Err    error
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("display %s is degraded: %v", e.Serial, e.Err)
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+// How often and how long apart a failing device is written again before it is marked degraded,
+// and how long a degraded device is left alone before it is written again
+var (
+	callRetries     = 2
+	retryDelay      = 10 * time.Millisecond
+	recoverInterval = time.Second
+)
+
+// transient reports whether a driver call may succeed when it is tried again. Failures that retrying
+// can't fix, such as a bad argument or an unknown device, are not transient.
+func transient(err error) bool {
+	return !errors.Is(err, ErrPageNotActive) && !errors.Is(err, ErrInvalidHandle) &&
+		!errors.Is(err, ErrInvalidArg) && !errors.Is(err, ErrNotImplemented)
+}
+
+// deviceCall calls the driver for a device. After a transient failure the device is written again in the
+// background, so the other devices aren't held up while waiting, and a device that keeps failing is marked
+// degraded until a later call succeeds. A page that is not active does not degrade the device,
+// as the page callback may just not have caught up. Must be called with the lock held.
+func deviceCall(d *deviceState, fn func() error) error {
+	err := fn()
+	switch {
+	case err == nil:
+		if d.degraded != nil {
+			log.Infof("Display %s has recovered", d.serial)
+			d.degraded = nil
+		}
+	case errors.Is(err, ErrPageNotActive):
+		log.Debugln("Page is not active:", err)
+	case d.degraded == nil && d.retries < callRetries && transient(err):
+		// The calls that fail until the retry are part of the same failure
+		if !d.retrying {
+			log.Debugf("Display %s failed, writing it again: %v", d.serial, err)
+			recoverDevice(d, retryDelay<<d.retries)
+			d.retries++
+		}
+	default:
+		if d.degraded == nil {
+			log.Warnf("Display %s is degraded: %v", d.serial, err)
+		}
+		d.degraded = &DeviceError{Serial: d.serial, Err: err}
+		recoverDevice(d, recoverInterval)
+	}
+	return err
+}
+
+// recoverDevice writes the lines and LEDs of a failing device again once the delay has passed.
+// Must be called with the lock held.
+func recoverDevice(d *deviceState, delay time.Duration) {
+	cancelWrite(d)
+	d.retrying = true
+	d.writeTimer = time.AfterFunc(delay, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		d.writeTimer = nil
+		d.retrying = false
+		if d.handle == 0 || devices[d.handle] != d {
+			return
+		}
+		log.Debugf("Rewriting display %s", d.serial)
+		forgetWrites(d)
+		applyLeds(d)
+		writeDevice(d)
+		if !d.retrying && d.degraded == nil {
+			d.retries = 0
+		}
+	})
+}
+
+// deviceErrors returns the errors of all degraded devices. Must be called with the lock held.
+func deviceErrors() error {
+	var errs []error
+	for _, handle := range deviceOrder {
+		if err := devices[handle].degraded; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,119 @@
+package mfd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// setTestRecovery makes failing writes retry and recover quickly for the duration of a test
+func setTestRecovery(t *testing.T) {
+	t.Helper()
+	delay, interval := retryDelay, recoverInterval
+	retryDelay, recoverInterval = time.Millisecond, 20*time.Millisecond
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		retryDelay, recoverInterval = delay, interval
+	})
+}
+
+func TestHResultErrorMatchesCode(t *testing.T) {
+	err := error(&HResultError{Proc: "DirectOutput_SetString", Code: E_PAGENOTACTIVE})
+	if !errors.Is(err, ErrPageNotActive) {
+		t.Errorf("%v does not match ErrPageNotActive", err)
+	}
+	if errors.Is(err, ErrBufferTooSmall) {
+		t.Errorf("%v matches ErrBufferTooSmall", err)
+	}
+	if got, want := err.Error(), "DirectOutput_SetString failed with hresult 0xff040001 (E_PAGENOTACTIVE)"; got != want {
+		t.Errorf("got %q, wanted %q", got, want)
+	}
+}
+
+func TestTransientFailureIsRetried(t *testing.T) {
+	setTestRecovery(t)
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	fake.Fail(FakeDevice, callRetries, ErrOutOfMemory)
+	if err := UpdateDisplay(testDisplay()); err != nil {
+		t.Fatalf("got %v, wanted the failed writes to be retried", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for fake.Line(FakeDevice, 0, 2) != "C" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for l, want := range []string{"A", "B", "C"} {
+		if got := fake.Line(FakeDevice, 0, uint32(l)); got != want {
+			t.Errorf("line %d: got %q, wanted %q", l, got, want)
+		}
+	}
+}
+
+func TestRetryDoesNotBlock(t *testing.T) {
+	setTestRecovery(t)
+	retryDelay = time.Hour
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	fake.Fail(FakeDevice, 1, ErrOutOfMemory)
+	start := time.Now()
+	UpdateDisplay(testDisplay())
+	LayoutGeometry()
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %v for the retry of a failed write", waited)
+	}
+}
+
+func TestFailingDeviceDegradesAndRecovers(t *testing.T) {
+	setTestRecovery(t)
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	fake.Fail(FakeDevice, -1, ErrOutOfMemory)
+	// The device is only degraded once the retries failed as well
+	err := UpdateDisplay(testDisplay())
+	deadline := time.Now().Add(time.Second)
+	for err == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		err = UpdateDisplay(testDisplay())
+	}
+	var deviceErr *DeviceError
+	if !errors.As(err, &deviceErr) || deviceErr.Serial != "FAKE-1" {
+		t.Fatalf("got %v, wanted a DeviceError for FAKE-1", err)
+	}
+	if !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("got %v, wanted it to wrap ErrOutOfMemory", err)
+	}
+
+	fake.Fail(FakeDevice, 0, nil)
+	deadline = time.Now().Add(time.Second)
+	for fake.Line(FakeDevice, 0, 2) != "C" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for l, want := range []string{"A", "B", "C"} {
+		if got := fake.Line(FakeDevice, 0, uint32(l)); got != want {
+			t.Errorf("after recovering line %d: got %q, wanted %q", l, got, want)
+		}
+	}
+	if err := UpdateDisplay(testDisplay()); err != nil {
+		t.Errorf("got %v after recovering, wanted no error", err)
+	}
+}
+
+func TestInactivePageDoesNotDegrade(t *testing.T) {
+	setTestRecovery(t)
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	fake.Fail(FakeDevice, 1, &HResultError{Proc: "DirectOutput_SetString", Code: E_PAGENOTACTIVE})
+	if err := UpdateDisplay(testDisplay()); err != nil {
+		t.Errorf("got %v, wanted an inactive page to be ignored", err)
+	}
+}
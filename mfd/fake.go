@@ -0,0 +1,337 @@
+package mfd
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"sync"
+)
+
+// FakeDevice is the handle of the device a new Fake backend starts with
+const FakeDevice uintptr = 1
+
+// FakeWrite records a single SetString call on a Fake backend
+type FakeWrite struct {
+	Page uint32
+	Line uint32
+	Text string
+}
+
+// fakeDevice holds everything written to a single device of a Fake backend
+type fakeDevice struct {
+	serial   string
+	attached bool
+	isImage  bool
+	geometry Geometry
+	pages    []uint32
+	writes   []FakeWrite
+	lines    map[uint32]map[uint32]string
+	leds     map[uint32]map[uint32]bool
+	images   map[uint32]image.Image
+	onPage   PageChangeFunc
+	onButton SoftButtonFunc
+	// The number of writes still to fail and the error they fail with
+	failures int
+	failErr  error
+}
+
+// fail returns the error the next write fails with, or nil. Must be called with the lock held.
+func (d *fakeDevice) fail() error {
+	if d.failures == 0 {
+		return nil
+	}
+	d.failures--
+	return d.failErr
+}
+
+// Fake is an in-memory Backend that records every write.
+// It allows running and testing the display pipeline without a device attached.
+type Fake struct {
+	mu sync.Mutex
+
+	devices  map[uintptr]*fakeDevice
+	order    []uintptr
+	onDevice DeviceChangeFunc
+}
+
+// NewFake returns a Fake backend with the device FakeDevice already attached
+func NewFake() *Fake {
+	f := &Fake{devices: map[uintptr]*fakeDevice{}}
+	f.device(FakeDevice).attached = true
+	return f
+}
+
+// device returns the recorded state of a device, creating it if needed. Must be called with the lock held.
+func (f *Fake) device(handle uintptr) *fakeDevice {
+	d, ok := f.devices[handle]
+	if !ok {
+		d = &fakeDevice{
+			serial: fmt.Sprintf("FAKE-%d", handle),
+			lines:  map[uint32]map[uint32]string{},
+			leds:   map[uint32]map[uint32]bool{},
+			images: map[uint32]image.Image{},
+		}
+		f.devices[handle] = d
+		f.order = append(f.order, handle)
+	}
+	return d
+}
+
+// Initialize implements Backend
+func (f *Fake) Initialize() error {
+	return nil
+}
+
+// Deinitialize implements Backend
+func (f *Fake) Deinitialize() error {
+	return nil
+}
+
+// RegisterDeviceCallback implements Backend
+func (f *Fake) RegisterDeviceCallback(fn DeviceChangeFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onDevice = fn
+	return nil
+}
+
+// Enumerate implements Backend
+func (f *Fake) Enumerate(fn func(device uintptr)) error {
+	f.mu.Lock()
+	attached := []uintptr{}
+	for _, handle := range f.order {
+		if f.devices[handle].attached {
+			attached = append(attached, handle)
+		}
+	}
+	f.mu.Unlock()
+	for _, handle := range attached {
+		fn(handle)
+	}
+	return nil
+}
+
+// GetSerialNumber implements Backend
+func (f *Fake) GetSerialNumber(device uintptr) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.devices[device]
+	if !ok {
+		return "", fmt.Errorf("unknown device %d", device)
+	}
+	return d.serial, nil
+}
+
+// RegisterPageCallback implements Backend
+func (f *Fake) RegisterPageCallback(device uintptr, fn PageChangeFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.device(device).onPage = fn
+	return nil
+}
+
+// RegisterSoftButtonCallback implements Backend
+func (f *Fake) RegisterSoftButtonCallback(device uintptr, fn SoftButtonFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.device(device).onButton = fn
+	return nil
+}
+
+// AddPage implements Backend
+func (f *Fake) AddPage(device uintptr, page uint32, active bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.device(device)
+	if err := d.fail(); err != nil {
+		return err
+	}
+	d.pages = append(d.pages, page)
+	return nil
+}
+
+// RemovePage implements Backend
+func (f *Fake) RemovePage(device uintptr, page uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.device(device)
+	if err := d.fail(); err != nil {
+		return err
+	}
+	i := slices.Index(d.pages, page)
+	if i < 0 {
+		return fmt.Errorf("unknown page %d", page)
+	}
+	d.pages = slices.Delete(d.pages, i, i+1)
+	delete(d.lines, page)
+	delete(d.images, page)
+	delete(d.leds, page)
+	return nil
+}
+
+// SetString implements Backend
+func (f *Fake) SetString(device uintptr, page, line uint32, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.device(device)
+	if err := d.fail(); err != nil {
+		return err
+	}
+	d.writes = append(d.writes, FakeWrite{Page: page, Line: line, Text: text})
+	if d.lines[page] == nil {
+		d.lines[page] = map[uint32]string{}
+	}
+	d.lines[page][line] = text
+	return nil
+}
+
+// SetLed implements Backend
+func (f *Fake) SetLed(device uintptr, page, led uint32, on bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.device(device)
+	if err := d.fail(); err != nil {
+		return err
+	}
+	if d.leds[page] == nil {
+		d.leds[page] = map[uint32]bool{}
+	}
+	d.leds[page][led] = on
+	return nil
+}
+
+// IsImageDevice implements ImageBackend
+func (f *Fake) IsImageDevice(device uintptr) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.device(device).isImage
+}
+
+// SetImage implements ImageBackend
+func (f *Fake) SetImage(device uintptr, page uint32, img image.Image) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.device(device)
+	if err := d.fail(); err != nil {
+		return err
+	}
+	d.images[page] = img
+	return nil
+}
+
+// Geometry implements GeometryBackend
+func (f *Fake) Geometry(device uintptr) Geometry {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.device(device).geometry
+}
+
+// SetGeometry sets the size of the display of a device. Must be called before the device is plugged in.
+// Devices without a geometry are the size of the X52 Pro MFD.
+func (f *Fake) SetGeometry(device uintptr, g Geometry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.device(device).geometry = g
+}
+
+// SetImageDevice makes a device show images like a Flight Instrument Panel. Must be called before the device is plugged in.
+func (f *Fake) SetImageDevice(device uintptr, isImage bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.device(device).isImage = isImage
+}
+
+// Image returns the image last set on a page
+func (f *Fake) Image(device uintptr, page uint32) image.Image {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.device(device).images[page]
+}
+
+// Pages returns the pages added to a device, in order
+func (f *Fake) Pages(device uintptr) []uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]uint32{}, f.device(device).pages...)
+}
+
+// Writes returns every SetString call made on a device so far, in order
+func (f *Fake) Writes(device uintptr) []FakeWrite {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeWrite{}, f.device(device).writes...)
+}
+
+// Line returns the text last written to a line of a page
+func (f *Fake) Line(device uintptr, page, line uint32) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.device(device).lines[page][line]
+}
+
+// Led returns the last state written to an LED of a page
+func (f *Fake) Led(device uintptr, page, led uint32) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.device(device).leds[page][led]
+}
+
+// Plug simulates plugging a device in. A device that was never attached before gets the serial number FAKE-<handle>.
+func (f *Fake) Plug(device uintptr) {
+	f.mu.Lock()
+	f.device(device).attached = true
+	fn := f.onDevice
+	f.mu.Unlock()
+	if fn != nil {
+		fn(device, true)
+	}
+}
+
+// Unplug simulates removing a device
+func (f *Fake) Unplug(device uintptr) {
+	f.mu.Lock()
+	d := f.device(device)
+	d.attached = false
+	d.pages = nil
+	fn := f.onDevice
+	f.mu.Unlock()
+	if fn != nil {
+		fn(device, false)
+	}
+}
+
+// TurnPage simulates using the page scroll wheel of a device
+func (f *Fake) TurnPage(device uintptr, page uint32, active bool) {
+	f.mu.Lock()
+	fn := f.device(device).onPage
+	f.mu.Unlock()
+	if fn != nil {
+		fn(device, page, active)
+	}
+}
+
+// Fail makes the next n page, line, LED and image writes to a device fail with the given error.
+// A negative n makes all writes fail until Fail is called again.
+func (f *Fake) Fail(device uintptr, n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.device(device)
+	d.failures = n
+	d.failErr = err
+}
+
+// PressButtons simulates pressing and releasing soft buttons of a device
+func (f *Fake) PressButtons(device uintptr, buttons uint32) {
+	f.SetButtons(device, buttons)
+	f.SetButtons(device, 0)
+}
+
+// SetButtons simulates the soft buttons of a device changing to the given held down buttons
+func (f *Fake) SetButtons(device uintptr, buttons uint32) {
+	f.mu.Lock()
+	fn := f.device(device).onButton
+	f.mu.Unlock()
+	if fn != nil {
+		fn(device, buttons)
+	}
+}
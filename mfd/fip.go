@@ -0,0 +1,258 @@
+package mfd
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+
+	"github.com/pellux-network/EDx52display/assets"
+)
+
+// The size of the Saitek Flight Instrument Panel screen in pixels
+const (
+	FIPWidth  = 320
+	FIPHeight = 240
+)
+
+// Layout of the rendered FIP page
+const (
+	fipMargin    = 10
+	fipHeader    = 44
+	fipRowHeight = 20
+	fipTitleRow  = 28
+	fipIconSize  = 28
+)
+
+// The colours of the app icon and diagrams in assets/
+var (
+	fipBackground = color.RGBA{0x01, 0x1a, 0x0b, 0xff}
+	fipForeground = color.RGBA{0x0b, 0xf6, 0x6a, 0xff}
+	fipDim        = color.RGBA{0x06, 0x7a, 0x35, 0xff}
+)
+
+var (
+	fontsOnce   sync.Once
+	fontRegular font.Face
+	fontBold    font.Face
+	fontTitle   font.Face
+
+	iconsOnce sync.Once
+	icons     = map[string]image.Image{}
+)
+
+func loadFonts() {
+	fontsOnce.Do(func() {
+		fontRegular = mustFace(gomono.TTF, 15)
+		fontBold = mustFace(gomonobold.TTF, 17)
+		fontTitle = mustFace(gomonobold.TTF, 22)
+	})
+}
+
+func mustFace(ttf []byte, size float64) font.Face {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err)
+	}
+	return face
+}
+
+// loadIcons decodes the icons in assets/fip, keyed by file name without the extension
+func loadIcons() {
+	iconsOnce.Do(func() {
+		files, err := fs.Glob(assets.FIPIcons, "fip/*.png")
+		if err != nil {
+			panic(err)
+		}
+		for _, file := range files {
+			f, err := assets.FIPIcons.Open(file)
+			if err != nil {
+				panic(err)
+			}
+			img, err := png.Decode(f)
+			f.Close()
+			if err != nil {
+				panic(fmt.Errorf("unable to decode %s: %w", file, err))
+			}
+			icons[strings.TrimSuffix(path.Base(file), ".png")] = img
+		}
+	})
+}
+
+// RenderImage renders a page for a Flight Instrument Panel, scrolled to the given line.
+// The first line of the page is drawn as a header and stays in place while the rest scrolls.
+// The rows, icon and gauge of the page set how the lines are drawn.
+func RenderImage(page Page, line uint32) *image.RGBA {
+	loadFonts()
+	loadIcons()
+	img := image.NewRGBA(image.Rect(0, 0, FIPWidth, FIPHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(fipBackground), image.Point{}, draw.Src)
+	drawFrame(img)
+
+	if len(page.Lines) == 0 {
+		return img
+	}
+	drawHeader(img, page.row(0), page.Icon)
+
+	y := fipHeader + 6
+	first := 1
+	if len(page.Lines) > 1 && page.row(1).Style == RowTitle {
+		drawText(img, fontTitle, fipForeground, fipMargin+4, y+20, page.row(1).Left)
+		y += fipTitleRow
+		first = 2
+	}
+	if page.Gauge != nil {
+		drawBar(img, y+2, page.Gauge.Value, page.Gauge.Max)
+		y += 14
+	}
+
+	total := len(page.Lines) - first
+	start := min(int(line), total)
+	rows := (FIPHeight - fipMargin - y) / fipRowHeight
+	for i := 0; i < rows && start+i < total; i++ {
+		drawRow(img, y+i*fipRowHeight, page.row(first+start+i))
+	}
+	drawScrollbar(img, y, rows, start, total)
+	return img
+}
+
+// WritePNG renders a page for a Flight Instrument Panel and writes it as PNG
+func WritePNG(w io.Writer, page Page, line uint32) error {
+	return png.Encode(w, RenderImage(page, line))
+}
+
+// row returns the row of a line, or the line as plain text when it has none
+func (p Page) row(line int) Row {
+	if row, ok := p.Rows[line]; ok {
+		return row
+	}
+	return Row{Left: strings.TrimSpace(p.Lines[line])}
+}
+
+// drawFrame draws the chamfered outline used in the diagrams in assets/
+func drawFrame(img *image.RGBA) {
+	const c = 12
+	w, h := float32(FIPWidth-1), float32(FIPHeight-1)
+	outer := []vec{{0, 0}, {w - c, 0}, {w, c}, {w, h}, {c, h}, {0, h - c}}
+	inner := []vec{{2, 2}, {w - c - 1, 2}, {w - 2, c + 1}, {w - 2, h - 2}, {c + 1, h - 2}, {2, h - c - 1}}
+	fillPolygon(img, fipDim, outer)
+	fillPolygon(img, fipBackground, inner)
+}
+
+func drawHeader(img *image.RGBA, header Row, icon string) {
+	x := fipMargin + 4
+	if icon != "" {
+		drawIcon(img, icon, x, fipMargin+2)
+		x += fipIconSize + 8
+	}
+	drawText(img, fontBold, fipForeground, x, fipMargin+22, header.Left)
+	switch {
+	case header.RightIcon != "":
+		drawIcon(img, header.RightIcon, FIPWidth-fipMargin-18, fipMargin+6)
+	case header.Right != "":
+		drawText(img, fontBold, fipForeground, FIPWidth-fipMargin-4-textWidth(fontBold, header.Right), fipMargin+22, header.Right)
+	}
+	fillRect(img, fipForeground, image.Rect(fipMargin, fipHeader, FIPWidth-fipMargin, fipHeader+2))
+}
+
+func drawRow(img *image.RGBA, y int, row Row) {
+	if row.Style == RowSeparator {
+		mid := y + fipRowHeight/2
+		fillRect(img, fipDim, image.Rect(fipMargin+4, mid-1, FIPWidth-fipMargin-12, mid+1))
+		if row.Left != "" {
+			w := textWidth(fontRegular, row.Left)
+			x := (FIPWidth - w) / 2
+			fillRect(img, fipBackground, image.Rect(x-6, y, x+w+6, y+fipRowHeight))
+			drawText(img, fontRegular, fipDim, x, y+15, row.Left)
+		}
+		return
+	}
+	drawText(img, fontRegular, fipForeground, fipMargin+4, y+15, row.Left)
+	switch {
+	case row.RightIcon != "":
+		drawIcon(img, row.RightIcon, FIPWidth-fipMargin-30, y+2)
+	case row.Right != "":
+		drawText(img, fontRegular, fipForeground, FIPWidth-fipMargin-14-textWidth(fontRegular, row.Right), y+15, row.Right)
+	}
+}
+
+// drawBar draws a capacity bar, used for the cargo hold
+func drawBar(img *image.RGBA, y, value, capacity int) {
+	r := image.Rect(fipMargin+4, y, FIPWidth-fipMargin-14, y+8)
+	fillRect(img, fipDim, r)
+	if capacity > 0 {
+		if value > capacity {
+			value = capacity
+		}
+		filled := r.Dx() * value / capacity
+		fillRect(img, fipForeground, image.Rect(r.Min.X, r.Min.Y, r.Min.X+filled, r.Max.Y))
+	}
+}
+
+func drawScrollbar(img *image.RGBA, top, rows, start, total int) {
+	if total <= rows {
+		return
+	}
+	x := FIPWidth - fipMargin - 6
+	bottom := FIPHeight - fipMargin - 4
+	fillRect(img, fipDim, image.Rect(x+1, top, x+3, bottom))
+	height := max((bottom-top)*rows/total, 8)
+	pos := top + (bottom-top-height)*start/max(total-rows, 1)
+	fillRect(img, fipForeground, image.Rect(x, pos, x+4, pos+height))
+}
+
+func drawText(img *image.RGBA, face font.Face, c color.Color, x, y int, text string) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(text)
+}
+
+func textWidth(face font.Face, text string) int {
+	return font.MeasureString(face, text).Round()
+}
+
+func fillRect(img *image.RGBA, c color.Color, r image.Rectangle) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+type vec struct {
+	x, y float32
+}
+
+func fillPolygon(img *image.RGBA, c color.Color, points []vec) {
+	b := img.Bounds()
+	r := vector.NewRasterizer(b.Dx(), b.Dy())
+	r.MoveTo(points[0].x, points[0].y)
+	for _, p := range points[1:] {
+		r.LineTo(p.x, p.y)
+	}
+	r.ClosePath()
+	r.Draw(img, b, image.NewUniform(c), image.Point{})
+}
+
+// drawIcon draws an icon from assets/fip in the foreground colour with its top left corner at x, y.
+// Unknown icons are left out.
+func drawIcon(img *image.RGBA, name string, x, y int) {
+	icon, ok := icons[name]
+	if !ok {
+		return
+	}
+	b := icon.Bounds()
+	draw.DrawMask(img, b.Sub(b.Min).Add(image.Pt(x, y)), image.NewUniform(fipForeground), image.Point{}, icon, b.Min, draw.Over)
+}
@@ -0,0 +1,91 @@
+package mfd
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Set FIP_PNG_DIR to keep the rendered pages for inspection, e.g. as CI artifacts
+func pngDir(t *testing.T) string {
+	if dir := os.Getenv("FIP_PNG_DIR"); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	return t.TempDir()
+}
+
+func TestRenderImage(t *testing.T) {
+	system := Page{Icon: IconStar}
+	system.AddRow(16, Row{Left: "CURR SYSTEM", Right: "FUEL", RightIcon: IconFuel})
+	system.AddRow(16, Row{Style: RowTitle, Left: "Shinrarta Dezhra"})
+	system.AddRow(16, Row{Left: "CLS:K"})
+	system.Add("Yellow-Orange Star")
+	system.AddRow(16, Row{Left: "Bodies:", Right: "15"})
+	system.AddRow(16, Row{Left: "Scan:", Right: "1,234,567cr"})
+	system.AddRow(16, Row{Style: RowSeparator, Left: "VAL BODIES"})
+	system.AddRow(16, Row{Left: "A 1", Right: "512,000cr"})
+	cargo := Page{Icon: IconCargo, Gauge: &Gauge{Value: 12, Max: 64}}
+	cargo.AddRow(16, Row{Left: "CARGO:", Right: "0012/0064"})
+	cargo.AddRow(16, Row{Left: "Gold", Right: "8"})
+	cargo.AddRow(16, Row{Left: "Silver", Right: "4"})
+	pages := map[string]Page{
+		"system": system,
+		"cargo":  cargo,
+		// Pages without rows are drawn as plain text
+		"text":  {Lines: []string{"CURR SYSTEM", "Sol", "Bodies:      15"}},
+		"empty": {},
+	}
+	dir := pngDir(t)
+	for name, page := range pages {
+		var buf bytes.Buffer
+		if err := WritePNG(&buf, page, 0); err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if b := img.Bounds(); b.Dx() != FIPWidth || b.Dy() != FIPHeight {
+			t.Errorf("%s: got a %dx%d image", name, b.Dx(), b.Dy())
+		}
+		// The header separator is drawn in the foreground colour
+		r, g, b, _ := img.At(FIPWidth/2, fipHeader).RGBA()
+		if fg := (len(page.Lines) > 0); fg != (r>>8 == 0x0b && g>>8 == 0xf6 && b>>8 == 0x6a) {
+			t.Errorf("%s: unexpected header separator colour %x %x %x", name, r>>8, g>>8, b>>8)
+		}
+		// The header icon from assets/ is drawn in the foreground colour
+		r, g, b, _ = img.At(fipMargin+4+fipIconSize/2, fipMargin+2+fipIconSize/2).RGBA()
+		if icon := page.Icon != ""; icon != (r>>8 == 0x0b && g>>8 == 0xf6 && b>>8 == 0x6a) {
+			t.Errorf("%s: unexpected colour %x %x %x in the middle of the icon", name, r>>8, g>>8, b>>8)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".png"), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImageDevice(t *testing.T) {
+	const fip uintptr = 2
+	fake := NewFake()
+	fake.SetImageDevice(fip, true)
+	fake.Plug(fip)
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+
+	if fake.Image(fip, 0) == nil {
+		t.Error("no image was set on the image device")
+	}
+	if len(fake.Writes(fip)) != 0 {
+		t.Error("text was written to the image device")
+	}
+	if got := fake.Line(FakeDevice, 0, 0); got != "A" {
+		t.Errorf("text device shows %q, wanted %q", got, "A")
+	}
+}
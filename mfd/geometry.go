@@ -0,0 +1,93 @@
+package mfd
+
+// Geometry is the size of a character display
+type Geometry struct {
+	// Width is the number of characters on a line
+	Width int `json:"width"`
+	// Lines is the number of lines shown at once
+	Lines int `json:"lines"`
+}
+
+// X52Pro is the geometry of the X52 Pro MFD, the geometry of devices whose backend doesn't report one
+var X52Pro = Geometry{Width: 16, Lines: 3}
+
+// GeometryBackend is implemented by backends whose devices are not the size of the X52 Pro MFD
+type GeometryBackend interface {
+	// Geometry returns the size of the display of a device
+	Geometry(device uintptr) Geometry
+}
+
+// The geometry pages were last laid out for and the functions notified when it changes
+var (
+	layout          = X52Pro
+	layoutListeners = map[int]func(Geometry){}
+	// The key of the next layout listener
+	nextLayoutListener int
+)
+
+// LayoutGeometry returns the geometry pages should be laid out for. This is the smallest geometry
+// of the attached text displays, so the pages fit on all of them, or the X52 Pro MFD while none is attached.
+func LayoutGeometry() Geometry {
+	mu.Lock()
+	defer mu.Unlock()
+	return layoutGeometry()
+}
+
+// AddLayoutListener registers a function that is called with the new layout geometry when it changes,
+// for example when a device of another size is plugged in. The function is called from its own goroutine.
+// The returned function removes the listener again.
+func AddLayoutListener(fn func(Geometry)) (remove func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	key := nextLayoutListener
+	nextLayoutListener++
+	layoutListeners[key] = fn
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(layoutListeners, key)
+	}
+}
+
+// layoutGeometry returns the geometry pages should be laid out for. Must be called with the lock held.
+func layoutGeometry() Geometry {
+	var g Geometry
+	for _, handle := range deviceOrder {
+		d := devices[handle]
+		if d.image {
+			continue
+		}
+		if g.Width == 0 {
+			g = d.geometry
+			continue
+		}
+		g.Width = min(g.Width, d.geometry.Width)
+		g.Lines = min(g.Lines, d.geometry.Lines)
+	}
+	if g.Width == 0 {
+		return virtualDevice.geometry
+	}
+	return g
+}
+
+// updateLayout notifies the layout listeners when the layout geometry changed. Must be called with the lock held.
+func updateLayout() {
+	g := layoutGeometry()
+	if g == layout {
+		return
+	}
+	layout = g
+	for _, fn := range layoutListeners {
+		go fn(g)
+	}
+}
+
+// deviceGeometry returns the geometry of a device, as reported by the backend. Must be called with the lock held.
+func deviceGeometry(handle uintptr) Geometry {
+	if gb, ok := backend.(GeometryBackend); ok {
+		if g := gb.Geometry(handle); g.Width > 0 && g.Lines > 0 {
+			return g
+		}
+	}
+	return X52Pro
+}
@@ -0,0 +1,89 @@
+package mfd
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeometry(t *testing.T) {
+	fake := NewFake()
+	fake.SetGeometry(FakeDevice, Geometry{Width: 20, Lines: 4})
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := LayoutGeometry(), (Geometry{Width: 20, Lines: 4}); got != want {
+		t.Errorf("got layout %+v, wanted %+v", got, want)
+	}
+	display := testDisplay()
+	display.Pages[0].Lines[0] = "A line of twenty ch."
+	UpdateDisplay(display)
+	for l, want := range []string{"A line of twenty ch.", "B", "C", "D"} {
+		if got := fake.Line(FakeDevice, 0, uint32(l)); got != want {
+			t.Errorf("line %d: got %q, wanted %q", l, got, want)
+		}
+	}
+}
+
+func TestLayoutFollowsSmallestDevice(t *testing.T) {
+	fake := NewFake()
+	fake.SetGeometry(FakeDevice, Geometry{Width: 40, Lines: 2})
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan Geometry, 2)
+	remove := AddLayoutListener(func(g Geometry) {
+		select {
+		case changes <- g:
+		default:
+		}
+	})
+
+	// The pages are laid out to fit the width of the X52 Pro and the lines of the larger display
+	fake.Plug(2)
+	want := Geometry{Width: 16, Lines: 2}
+	if got := LayoutGeometry(); got != want {
+		t.Errorf("got layout %+v with an X52 Pro attached, wanted %+v", got, want)
+	}
+	select {
+	case g := <-changes:
+		if g != want {
+			t.Errorf("listener got %+v, wanted %+v", g, want)
+		}
+	case <-time.After(time.Second):
+		t.Error("the layout listener was not called")
+	}
+
+	remove()
+	fake.Unplug(2)
+	if got, want := LayoutGeometry(), (Geometry{Width: 40, Lines: 2}); got != want {
+		t.Errorf("got layout %+v after unplugging, wanted %+v", got, want)
+	}
+	select {
+	case g := <-changes:
+		t.Errorf("removed listener got %+v", g)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTerminalGeometry(t *testing.T) {
+	in, keys := io.Pipe()
+	var out bytes.Buffer
+	sim := NewTerminalSize(in, &out, Geometry{Width: 20, Lines: 4})
+	if err := InitDevice(sim, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+	io.WriteString(keys, "q")
+	<-sim.Done()
+
+	if got := sim.lines[0]; !slices.Equal(got, []string{"A", "B", "C", "D"}) {
+		t.Errorf("got lines %q on the first page", got)
+	}
+	if screen := out.String(); !strings.Contains(screen, "|D                   |") {
+		t.Errorf("fourth line was not drawn 20 characters wide:\n%s", screen)
+	}
+}
@@ -0,0 +1,309 @@
+package mfd
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Gestures made with the soft buttons
+const (
+	GestureClick       = "click"
+	GestureDoubleClick = "doubleclick"
+	GestureLongPress   = "longpress"
+	GestureUp          = "up"
+	GestureDown        = "down"
+	GestureSelectUp    = "select+up"
+	GestureSelectDown  = "select+down"
+)
+
+var gestureNames = []string{
+	GestureClick, GestureDoubleClick, GestureLongPress, GestureUp, GestureDown, GestureSelectUp, GestureSelectDown,
+}
+
+// Actions built into the display. ActionSelect runs the soft button callback passed to InitDevice.
+const (
+	ActionNone       = "none"
+	ActionSelect     = "select"
+	ActionScrollUp   = "scrollup"
+	ActionScrollDown = "scrolldown"
+	ActionNextPage   = "nextpage"
+	ActionPrevPage   = "prevpage"
+	ActionTop        = "top"
+	ActionBottom     = "bottom"
+	ActionDetail     = "detail"
+	ActionPin        = "pin"
+	ActionOpen       = "open"
+	ActionBack       = "back"
+	ActionDismiss    = "dismiss"
+)
+
+// deviceActions are the built-in actions that work on the device the gesture was made on
+var deviceActions = map[string]func(d *deviceState){
+	ActionScrollUp:   decrementLine,
+	ActionScrollDown: incrementLine,
+	ActionNextPage:   func(d *deviceState) { turnPage(d, 1) },
+	ActionPrevPage:   func(d *deviceState) { turnPage(d, -1) },
+	ActionTop:        scrollToTop,
+	ActionBottom:     scrollToBottom,
+	ActionDetail:     toggleDetail,
+	ActionPin:        togglePin,
+	ActionOpen:       openChild,
+	ActionBack:       closeChild,
+	ActionDismiss:    dismissOverlay,
+}
+
+// Gestures configures how soft button gestures are detected and what they do
+type Gestures struct {
+	// LongPress is how long select is held for a long press
+	LongPress time.Duration `json:"longPress"`
+	// DoubleClick is the longest time between the clicks of a double click
+	DoubleClick time.Duration `json:"doubleClick"`
+	// Bindings map gesture names to action names. Gestures not listed keep their default action.
+	Bindings map[string]string `json:"bindings,omitempty"`
+}
+
+var defaultBindings = map[string]string{
+	GestureClick: ActionSelect,
+	GestureUp:    ActionScrollUp,
+	GestureDown:  ActionScrollDown,
+}
+
+// The gesture timings and the action bound to each gesture
+var (
+	longPressTime   = 600 * time.Millisecond
+	doubleClickTime = 300 * time.Millisecond
+	bindings        = defaultBindings
+)
+
+// Actions registered by the application, keyed by name
+var actions = map[string]func(){}
+
+// gestureState tracks the soft buttons of a single device
+type gestureState struct {
+	// The buttons held down as of the last callback
+	buttons uint32
+	// Set once select was used for a chord or a long press, so releasing it is not a click
+	consumed bool
+	// Fires the long press while select is held
+	longPressTimer *time.Timer
+	// Fires a single click once no second click followed
+	clickTimer *time.Timer
+}
+
+// RegisterAction makes an application function available to gesture bindings under the given name.
+// The function is called without any lock held.
+func RegisterAction(name string, fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	actions[name] = fn
+}
+
+// SetGestures sets the gesture timings and bindings. Actions registered with RegisterAction must be registered first.
+func SetGestures(g Gestures) error {
+	mu.Lock()
+	defer mu.Unlock()
+	bound := map[string]string{}
+	for gesture, action := range defaultBindings {
+		bound[gesture] = action
+	}
+	for gesture, action := range g.Bindings {
+		if !knownGesture(gesture) {
+			return fmt.Errorf("unknown soft button gesture %q", gesture)
+		}
+		if !knownAction(action) {
+			return fmt.Errorf("unknown action %q for gesture %q", action, gesture)
+		}
+		bound[gesture] = action
+	}
+	if g.LongPress > 0 {
+		longPressTime = g.LongPress
+	}
+	if g.DoubleClick > 0 {
+		doubleClickTime = g.DoubleClick
+	}
+	bindings = bound
+	return nil
+}
+
+func knownGesture(name string) bool {
+	for _, gesture := range gestureNames {
+		if gesture == name {
+			return true
+		}
+	}
+	return false
+}
+
+// knownAction reports whether an action name is built in or registered. Must be called with the lock held.
+func knownAction(name string) bool {
+	if name == ActionNone || name == ActionSelect {
+		return true
+	}
+	if _, ok := deviceActions[name]; ok {
+		return true
+	}
+	_, ok := actions[name]
+	return ok
+}
+
+// handleButtons turns a soft button state change into gestures. It returns the application actions to run
+// once the lock is released. Must be called with the lock held.
+func handleButtons(d *deviceState, buttons uint32) []func() {
+	g := &d.gestures
+	pressed := buttons &^ g.buttons
+	released := g.buttons &^ buttons
+	repeated := buttons != 0 && pressed == 0 && released == 0
+	g.buttons = buttons
+
+	var run []func()
+	if pressed&softButton_Select != 0 {
+		g.consumed = false
+		stopTimer(&g.longPressTimer)
+		g.longPressTimer = time.AfterFunc(longPressTime, func() { longPress(d) })
+	}
+	// Every scroll notch is reported, even without a release in between
+	for _, scroll := range []struct {
+		button         uint32
+		gesture, chord string
+	}{{softButton_Up, GestureUp, GestureSelectUp}, {softButton_Down, GestureDown, GestureSelectDown}} {
+		if buttons&scroll.button == 0 || (pressed&scroll.button == 0 && !repeated) {
+			continue
+		}
+		if buttons&softButton_Select != 0 {
+			g.consumed = true
+			stopTimer(&g.longPressTimer)
+			run = append(run, gesture(d, scroll.chord)...)
+		} else {
+			run = append(run, gesture(d, scroll.gesture)...)
+		}
+	}
+	if released&softButton_Select != 0 {
+		stopTimer(&g.longPressTimer)
+		if !g.consumed {
+			run = append(run, click(d)...)
+		}
+	}
+	return run
+}
+
+// click handles releasing select, telling single from double clicks. Must be called with the lock held.
+func click(d *deviceState) []func() {
+	g := &d.gestures
+	if g.clickTimer != nil {
+		stopTimer(&g.clickTimer)
+		return gesture(d, GestureDoubleClick)
+	}
+	if action := bindings[GestureDoubleClick]; action == "" || action == ActionNone {
+		// Without a double click there is no need to wait for a second click
+		return gesture(d, GestureClick)
+	}
+	g.clickTimer = time.AfterFunc(doubleClickTime, func() {
+		mu.Lock()
+		d.gestures.clickTimer = nil
+		run := gesture(d, GestureClick)
+		mu.Unlock()
+		runActions(run)
+	})
+	return nil
+}
+
+func longPress(d *deviceState) {
+	mu.Lock()
+	g := &d.gestures
+	g.longPressTimer = nil
+	if g.buttons&softButton_Select == 0 {
+		mu.Unlock()
+		return
+	}
+	g.consumed = true
+	run := gesture(d, GestureLongPress)
+	mu.Unlock()
+	runActions(run)
+}
+
+// gesture runs the action bound to a gesture. Device actions run straight away, application actions
+// are returned to run once the lock is released. Must be called with the lock held.
+func gesture(d *deviceState, name string) []func() {
+	action := bindings[name]
+	log.Traceln("Gesture", name, "runs action", action)
+	if fn, ok := deviceActions[action]; ok {
+		fn(d)
+		return nil
+	}
+	fn := actions[action]
+	if action == ActionSelect {
+		fn = buttonCallback
+	}
+	if fn == nil {
+		return nil
+	}
+	return []func(){fn}
+}
+
+func runActions(run []func()) {
+	for _, fn := range run {
+		fn()
+	}
+}
+
+// cancelGestures stops the gesture timers of a device. Must be called with the lock held.
+func cancelGestures(d *deviceState) {
+	stopTimer(&d.gestures.longPressTimer)
+	stopTimer(&d.gestures.clickTimer)
+	d.gestures.buttons = 0
+}
+
+func stopTimer(t **time.Timer) {
+	if *t != nil {
+		(*t).Stop()
+		*t = nil
+	}
+}
+
+// scrollToTop shows the first line of the current page. Must be called with the lock held.
+func scrollToTop(d *deviceState) {
+	d.currentLines[d.viewKey()] = 0
+	d.marqueeTick = 0
+	refreshDisplay(d)
+}
+
+// scrollToBottom shows the last lines of the current page. Must be called with the lock held.
+func scrollToBottom(d *deviceState) {
+	lines := len(d.page().Lines)
+	d.currentLines[d.viewKey()] = uint32(max(lines-d.geometry.Lines, 0))
+	d.marqueeTick = 0
+	refreshDisplay(d)
+}
+
+// toggleDetail switches the device between the normal and the detailed lines of its pages.
+// Must be called with the lock held.
+func toggleDetail(d *deviceState) {
+	d.detail = !d.detail
+	d.currentLines[d.viewKey()] = 0
+	d.marqueeTick = 0
+	refreshDisplay(d)
+}
+
+// togglePin pins the current page to the device, or unpins it. A pinned page stays on the device
+// with its last content when it is left out of the display. Must be called with the lock held.
+func togglePin(d *deviceState) {
+	key := d.pageKey()
+	if d.pinned != "" {
+		log.Infof("Unpinned page %s", d.pinned)
+		unpinned := d.pinned
+		d.pinned = ""
+		syncPages(d)
+		if unpinned == key {
+			refreshDisplay(d)
+			return
+		}
+	}
+	if key != "" {
+		log.Infof("Pinned page %s", key)
+		d.pinned = key
+		d.pinnedPage = d.displayPage(key)
+	}
+	refreshDisplay(d)
+}
@@ -0,0 +1,139 @@
+package mfd
+
+import (
+	"io"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func setTestGestures(t *testing.T, bindings map[string]string) {
+	t.Helper()
+	err := SetGestures(Gestures{LongPress: 20 * time.Millisecond, DoubleClick: 20 * time.Millisecond, Bindings: bindings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		SetGestures(Gestures{LongPress: 600 * time.Millisecond, DoubleClick: 300 * time.Millisecond})
+	})
+}
+
+func TestGestures(t *testing.T) {
+	fake := NewFake()
+	var clicks, cleared atomic.Int32
+	if err := InitDevice(fake, testPages, func() { clicks.Add(1) }); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	UpdateDisplay(testDisplay())
+	RegisterAction("clearcache", func() { cleared.Add(1) })
+	setTestGestures(t, map[string]string{
+		GestureDoubleClick: "clearcache",
+		GestureLongPress:   ActionBottom,
+		GestureSelectDown:  ActionNextPage,
+	})
+
+	// A single click waits for a possible second click
+	fake.PressButtons(FakeDevice, softButton_Select)
+	time.Sleep(50 * time.Millisecond)
+	if clicks.Load() != 1 || cleared.Load() != 0 {
+		t.Errorf("single click: got %d clicks and %d double clicks", clicks.Load(), cleared.Load())
+	}
+
+	fake.PressButtons(FakeDevice, softButton_Select)
+	fake.PressButtons(FakeDevice, softButton_Select)
+	time.Sleep(50 * time.Millisecond)
+	if clicks.Load() != 1 || cleared.Load() != 1 {
+		t.Errorf("double click: got %d clicks and %d double clicks", clicks.Load(), cleared.Load())
+	}
+
+	fake.SetButtons(FakeDevice, softButton_Select)
+	time.Sleep(50 * time.Millisecond)
+	fake.SetButtons(FakeDevice, 0)
+	if got := fake.Line(FakeDevice, 0, 0); got != "B" {
+		t.Errorf("got %q after a long press, wanted the last lines", got)
+	}
+
+	// Scrolling with select held is a chord, releasing select afterwards is no click
+	fake.SetButtons(FakeDevice, softButton_Select)
+	fake.SetButtons(FakeDevice, softButton_Select|softButton_Down)
+	fake.SetButtons(FakeDevice, softButton_Select)
+	fake.SetButtons(FakeDevice, 0)
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	page := devices[FakeDevice].currentPage
+	mu.Unlock()
+	if page != 0 {
+		t.Errorf("got page %d after select+down, wanted the page kept on a device that can't be told", page)
+	}
+	if clicks.Load() != 1 {
+		t.Errorf("chord caused a click")
+	}
+}
+
+func TestGesturesTurnPage(t *testing.T) {
+	hostIn, display := io.Pipe()
+	displayIn, host := io.Pipe()
+	messages := readDisplay(displayIn)
+	s := NewSerial(serialPipe{hostIn, host}, "lcd", Geometry{Width: 20, Lines: 4})
+	if err := InitDevice(s, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	defer display.Close()
+	UpdateDisplay(testDisplay())
+	expectMessages(t, messages, "PAGE 1 2")
+	setTestGestures(t, map[string]string{GestureSelectDown: ActionNextPage, GestureSelectUp: ActionPrevPage})
+
+	// The display is told to show the page turned to
+	io.WriteString(display, "BUTTONS 1\nBUTTONS 5\nBUTTONS 1\nBUTTONS 0\n")
+	expectMessages(t, messages, "PAGE 2 2", "LINE 0 Second              ")
+	io.WriteString(display, "BUTTONS 1\nBUTTONS 3\nBUTTONS 1\nBUTTONS 0\n")
+	expectMessages(t, messages, "LINE 0 A                   ", "PAGE 1 2")
+}
+
+func TestSetGesturesRejectsUnknownNames(t *testing.T) {
+	if err := SetGestures(Gestures{Bindings: map[string]string{"wiggle": ActionTop}}); err == nil {
+		t.Error("expected an error for an unknown gesture")
+	}
+	if err := SetGestures(Gestures{Bindings: map[string]string{GestureClick: "launch"}}); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+}
+
+func TestDetailAndPin(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	setTestGestures(t, map[string]string{GestureClick: ActionDetail, GestureSelectUp: ActionPin})
+
+	first := Page{Key: "first", Lines: []string{"A"}, Detail: []string{"All about A"}}
+	second := Page{Key: "second", Lines: []string{"Second"}}
+	UpdateDisplay(Display{Pages: []Page{first, second}})
+
+	fake.PressButtons(FakeDevice, softButton_Select)
+	if got := fake.Line(FakeDevice, 0, 0); got != "All about A" {
+		t.Errorf("got %q in the detail view", got)
+	}
+	fake.PressButtons(FakeDevice, softButton_Select)
+	if got := fake.Line(FakeDevice, 0, 0); got != "A" {
+		t.Errorf("got %q after leaving the detail view", got)
+	}
+
+	// A pinned page stays with its last content while it is left out of the display
+	fake.PressButtons(FakeDevice, softButton_Select|softButton_Up)
+	UpdateDisplay(Display{Pages: []Page{second}})
+	if got := fake.Pages(FakeDevice); !slices.Equal(got, []uint32{0, 1}) {
+		t.Errorf("got pages %v, wanted the pinned page kept", got)
+	}
+	if got := fake.Line(FakeDevice, 0, 0); got != "A" {
+		t.Errorf("pinned page shows %q", got)
+	}
+	fake.PressButtons(FakeDevice, softButton_Select|softButton_Up)
+	if got := fake.Pages(FakeDevice); !slices.Equal(got, []uint32{1}) {
+		t.Errorf("got pages %v, wanted the unpinned page removed", got)
+	}
+}
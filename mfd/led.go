@@ -0,0 +1,211 @@
+package mfd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Led is one of the LEDs on the X52 Pro
+type Led int
+
+const (
+	LedFire Led = iota
+	LedFireA
+	LedFireB
+	LedFireD
+	LedFireE
+	LedToggle12
+	LedToggle34
+	LedToggle56
+	LedPOV2
+	LedClutch
+	LedThrottle
+)
+
+// LedColor is the colour an LED is lit in. Single colour LEDs are on for any colour but LedOff.
+type LedColor int
+
+const (
+	LedOff LedColor = iota
+	LedRed
+	LedGreen
+	LedAmber
+)
+
+// The interval at which blinking LEDs toggle
+const blinkInterval = 500 * time.Millisecond
+
+// ledIndex holds the DirectOutput LED indices for the red and green parts of an LED.
+// Single colour LEDs only have the red index set and green set to -1.
+type ledIndex struct {
+	red, green int
+}
+
+var ledIndices = map[Led]ledIndex{
+	LedFire:     {0, -1},
+	LedFireA:    {1, 2},
+	LedFireB:    {3, 4},
+	LedFireD:    {5, 6},
+	LedFireE:    {7, 8},
+	LedToggle12: {9, 10},
+	LedToggle34: {11, 12},
+	LedToggle56: {13, 14},
+	LedPOV2:     {15, 16},
+	LedClutch:   {17, 18},
+	LedThrottle: {19, -1},
+}
+
+var ledNames = map[string]Led{
+	"fire":      LedFire,
+	"fire_a":    LedFireA,
+	"fire_b":    LedFireB,
+	"fire_d":    LedFireD,
+	"fire_e":    LedFireE,
+	"toggle_12": LedToggle12,
+	"toggle_34": LedToggle34,
+	"toggle_56": LedToggle56,
+	"pov_2":     LedPOV2,
+	"clutch":    LedClutch,
+	"throttle":  LedThrottle,
+}
+
+var ledColorNames = map[string]LedColor{
+	"off":   LedOff,
+	"red":   LedRed,
+	"green": LedGreen,
+	"amber": LedAmber,
+}
+
+// ParseLed returns the LED with the given config name, e.g. "fire_a"
+func ParseLed(name string) (Led, error) {
+	led, ok := ledNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown LED %q", name)
+	}
+	return led, nil
+}
+
+// ParseLedColor returns the LED colour with the given config name, e.g. "amber"
+func ParseLedColor(name string) (LedColor, error) {
+	color, ok := ledColorNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown LED colour %q", name)
+	}
+	return color, nil
+}
+
+type ledState struct {
+	color LedColor
+	blink bool
+}
+
+// The requested state of every LED that has been set
+var leds = map[Led]ledState{}
+
+// Whether blinking LEDs are currently lit
+var blinkOn = true
+
+// Closed to stop the blink timer, nil while it is not running
+var blinkStop chan struct{}
+
+// SetLed sets an LED to the given colour on every page of every device, optionally blinking it
+func SetLed(led Led, color LedColor, blink bool) error {
+	if _, ok := ledIndices[led]; !ok {
+		return fmt.Errorf("unknown LED %d", led)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	state := ledState{color: color, blink: blink && color != LedOff}
+	if leds[led] == state {
+		return nil
+	}
+	leds[led] = state
+	applyLedAll(led)
+	updateBlinkTimer()
+	return nil
+}
+
+// applyLeds writes the state of every LED to a device. Must be called with the lock held.
+func applyLeds(d *deviceState) {
+	for led := range leds {
+		applyLed(d, led)
+	}
+}
+
+// applyLedAll writes the state of a single LED to every device. Must be called with the lock held.
+func applyLedAll(led Led) {
+	for _, d := range devices {
+		applyLed(d, led)
+	}
+}
+
+// applyLed writes the state of a single LED to every page of a device. Must be called with the lock held.
+func applyLed(d *deviceState, led Led) {
+	// The LEDs of image devices like the FIP light the soft buttons and are not mapped
+	if !d.loaded || d.handle == 0 || d.image {
+		return
+	}
+	state := leds[led]
+	color := state.color
+	if state.blink && !blinkOn {
+		color = LedOff
+	}
+	idx := ledIndices[led]
+	red := color == LedRed || color == LedAmber
+	green := color == LedGreen || color == LedAmber
+	if idx.green < 0 {
+		red = color != LedOff
+	}
+	for _, key := range d.pages {
+		p := d.pageIDs[key]
+		if err := deviceCall(d, func() error { return backend.SetLed(d.handle, p, uint32(idx.red), red) }); err != nil {
+			log.Debugln("Unable to set LED", led, err)
+		}
+		if idx.green >= 0 {
+			if err := deviceCall(d, func() error { return backend.SetLed(d.handle, p, uint32(idx.green), green) }); err != nil {
+				log.Debugln("Unable to set LED", led, err)
+			}
+		}
+	}
+}
+
+// updateBlinkTimer starts or stops the blink timer depending on whether any LED blinks.
+// Must be called with the lock held.
+func updateBlinkTimer() {
+	blinking := false
+	for _, state := range leds {
+		blinking = blinking || state.blink
+	}
+	switch {
+	case blinking && blinkStop == nil:
+		blinkStop = make(chan struct{})
+		go blink(blinkStop)
+	case !blinking && blinkStop != nil:
+		close(blinkStop)
+		blinkStop = nil
+		blinkOn = true
+	}
+}
+
+func blink(stop chan struct{}) {
+	ticker := time.NewTicker(blinkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mu.Lock()
+			blinkOn = !blinkOn
+			for led, state := range leds {
+				if state.blink {
+					applyLedAll(led)
+				}
+			}
+			mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package mfd
+
+import "time"
+
+// Marquee configures the horizontal scrolling of lines wider than the display
+type Marquee struct {
+	// Step is the time between shifting the lines by one character
+	Step time.Duration `json:"step"`
+	// Pause is the time the lines are held at either end
+	Pause time.Duration `json:"pause"`
+	// Pages are the names of the pages to scroll, all pages when empty
+	Pages []string `json:"pages,omitempty"`
+}
+
+// The active marquee configuration, nil while disabled
+var marquee *Marquee
+
+// Closed to stop the marquee timer, nil while it is not running
+var marqueeStop chan struct{}
+
+// SetMarquee enables horizontal scrolling of lines wider than the display
+func SetMarquee(m Marquee) {
+	if m.Step <= 0 {
+		m.Step = 300 * time.Millisecond
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	stopMarquee()
+	marquee = &m
+	marqueeStop = make(chan struct{})
+	go scrollMarquee(m.Step, marqueeStop)
+}
+
+// stopMarquee disables horizontal scrolling. Must be called with the lock held.
+func stopMarquee() {
+	if marqueeStop != nil {
+		close(marqueeStop)
+		marqueeStop = nil
+	}
+	marquee = nil
+}
+
+func scrollMarquee(step time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(step)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mu.Lock()
+			if len(deviceOrder) == 0 {
+				tickMarquee(virtualDevice)
+			}
+			for _, handle := range deviceOrder {
+				tickMarquee(devices[handle])
+			}
+			mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tickMarquee advances the marquee of a device and refreshes it if any visible line is scrolling.
+// Must be called with the lock held.
+func tickMarquee(d *deviceState) {
+	if d.image || !marqueeApplies(d) {
+		return
+	}
+	d.marqueeTick++
+	page := d.page()
+	start := int(d.visibleLine())
+	if o := overlayFor(d.pageKey()); o != nil {
+		page = Page{Lines: o.Lines}
+		start = 0
+	}
+	for l := start; l < start+d.geometry.Lines && l < len(page.Lines); l++ {
+		if DisplayWidth(page.Lines[l]) > d.geometry.Width {
+			refreshDisplay(d)
+			return
+		}
+	}
+}
+
+// marqueeApplies reports whether the current page of a device scrolls horizontally. Must be called with the lock held.
+func marqueeApplies(d *deviceState) bool {
+	if marquee == nil || int(d.currentPage) >= len(d.pages) {
+		return false
+	}
+	if len(marquee.Pages) == 0 {
+		return true
+	}
+	for _, name := range marquee.Pages {
+		if name == d.pageKey() {
+			return true
+		}
+	}
+	return false
+}
+
+// marqueeText returns the part of a line visible at the given marquee tick on a display of the given width.
+// The line is held at the start, scrolled to the end, held there and scrolled back. Must be called with the lock held.
+func marqueeText(text string, tick, width int) string {
+	runes := []rune(text)
+	travel := len(runes) - width
+	if travel <= 0 {
+		return text
+	}
+	pause := int(marquee.Pause / marquee.Step)
+	t := tick % (2*pause + 2*travel)
+	var offset int
+	switch {
+	case t < pause:
+		offset = 0
+	case t < pause+travel:
+		offset = t - pause + 1
+	case t < 2*pause+travel:
+		offset = travel
+	default:
+		offset = travel - (t - 2*pause - travel) - 1
+	}
+	return string(runes[offset : offset+width])
+}
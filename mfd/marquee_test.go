@@ -0,0 +1,72 @@
+package mfd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarquee(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	// A step this long never fires, the test advances the marquee by hand
+	SetMarquee(Marquee{Step: time.Hour, Pause: 2 * time.Hour})
+
+	display := testDisplay()
+	display.Pages[0].Lines[0] = "0123456789ABCDEFGH"
+	UpdateDisplay(display)
+
+	tick := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		tickMarquee(devices[FakeDevice])
+	}
+	// Held at the start for two steps, then scrolled to the end, held and scrolled back
+	for i, want := range []string{
+		"0123456789ABCDEF",
+		"0123456789ABCDEF",
+		"123456789ABCDEFG",
+		"23456789ABCDEFGH",
+		"23456789ABCDEFGH",
+		"23456789ABCDEFGH",
+		"123456789ABCDEFG",
+		"0123456789ABCDEF",
+	} {
+		if got := fake.Line(FakeDevice, 0, 0); got != want {
+			t.Errorf("tick %d: got %q, wanted %q", i, got, want)
+		}
+		if got := fake.Line(FakeDevice, 0, 1); got != "B" {
+			t.Errorf("tick %d: short line changed to %q", i, got)
+		}
+		tick()
+	}
+
+	// Scrolling restarts from the beginning when the view changes
+	fake.PressButtons(FakeDevice, softButton_Down)
+	fake.PressButtons(FakeDevice, softButton_Up)
+	if got := fake.Line(FakeDevice, 0, 0); got != "0123456789ABCDEF" {
+		t.Errorf("after scrolling got %q, wanted the start of the line", got)
+	}
+}
+
+func TestMarqueePages(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	SetMarquee(Marquee{Step: time.Hour, Pages: []string{"second"}})
+
+	display := testDisplay()
+	display.Pages[0].Lines[0] = "0123456789ABCDEFGH"
+	UpdateDisplay(display)
+
+	mu.Lock()
+	tickMarquee(devices[FakeDevice])
+	mu.Unlock()
+	if got := fake.Line(FakeDevice, 0, 0); got != display.Pages[0].Lines[0] {
+		t.Errorf("got %q on a page without marquee, wanted the full line", got)
+	}
+}
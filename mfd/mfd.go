@@ -4,7 +4,7 @@ import (
 	"fmt"
 )
 
-// Filename is the name of the file written with MFD data
+// Filename is the name of the file the display snapshot is written to
 const Filename = "mfd.json"
 
 // Display is the main display structure to write
@@ -25,7 +25,70 @@ func (d Display) Copy() Display {
 
 // Page is a single page of information to show on the MFD
 type Page struct {
+	// Key identifies the page across display updates. Pages without a key are matched to the
+	// page names passed to InitDevice by position.
+	Key   string   `json:"key,omitempty"`
 	Lines []string `json:"lines"`
+	// LineKeys optionally identify the lines, so the same lines stay in view when lines are added or removed
+	// above them. Lines without a key have an empty key, or no entry past the end.
+	LineKeys []string `json:"lineKeys,omitempty"`
+	// Detail holds optional detailed lines, shown instead of Lines in the detail view
+	Detail []string `json:"detail,omitempty"`
+	// Children are views opened by selecting a line, keyed by line index
+	Children map[int]Page `json:"children,omitempty"`
+	// Icon names the icon graphical displays draw in the header, the first line. One of the Icon constants.
+	Icon string `json:"icon,omitempty"`
+	// Rows describe the structure of lines for graphical displays, keyed by line index. Lines without a row are plain text.
+	Rows map[int]Row `json:"rows,omitempty"`
+	// Gauge is drawn below the header of graphical displays, nil for none
+	Gauge *Gauge `json:"gauge,omitempty"`
+}
+
+// The icons graphical displays can draw, from assets/fip
+const (
+	IconStar    = "star"
+	IconPlanet  = "planet"
+	IconStation = "station"
+	IconCargo   = "cargo"
+	IconFuel    = "fuel"
+)
+
+// RowStyle is how graphical displays draw a row
+type RowStyle int
+
+const (
+	// RowColumns has a left and a right aligned column
+	RowColumns RowStyle = iota
+	// RowTitle names what the page is about and is drawn large below the header
+	RowTitle
+	// RowSeparator starts a section, labelled with the left column
+	RowSeparator
+)
+
+// Row is the structure of a line, so graphical displays can draw it without parsing the text
+type Row struct {
+	Style RowStyle `json:"style,omitempty"`
+	Left  string   `json:"left,omitempty"`
+	Right string   `json:"right,omitempty"`
+	// RightIcon names an icon drawn in place of the right column, e.g. IconFuel
+	RightIcon string `json:"rightIcon,omitempty"`
+}
+
+// Text returns the row as a line width characters wide
+func (r Row) Text(width int) string {
+	switch {
+	case r.Style == RowSeparator:
+		return FillAround(width, "*", " "+r.Left+" ")
+	case r.Right == "":
+		return r.Left
+	}
+	return SpaceBetween(width, r.Left, r.Right)
+}
+
+// Gauge is a bar showing how full something is, such as the cargo hold
+type Gauge struct {
+	Value int `json:"value"`
+	Max   int `json:"max"`
 }
 
 // NewPage returns a new page
@@ -38,14 +101,84 @@ func (p *Page) Add(s string, args ...interface{}) {
 	p.Lines = append(p.Lines, fmt.Sprintf(s, args...))
 }
 
+// AddKeyed appends a new (optionally formatted) string identified by key
+func (p *Page) AddKeyed(key, s string, args ...interface{}) {
+	for len(p.LineKeys) < len(p.Lines) {
+		p.LineKeys = append(p.LineKeys, "")
+	}
+	p.LineKeys = append(p.LineKeys, key)
+	p.Add(s, args...)
+}
+
+// AddRow appends a line width characters wide made from a row, and keeps the row for graphical displays
+func (p *Page) AddRow(width int, row Row) {
+	p.Add("%s", row.Text(width))
+	p.setRow(row)
+}
+
+// AddKeyedRow appends a line made from a row identified by key, see AddRow and AddKeyed
+func (p *Page) AddKeyedRow(key string, width int, row Row) {
+	p.AddKeyed(key, "%s", row.Text(width))
+	p.setRow(row)
+}
+
+// setRow keeps the row of the last line added to the page
+func (p *Page) setRow(row Row) {
+	if p.Rows == nil {
+		p.Rows = map[int]Row{}
+	}
+	p.Rows[len(p.Lines)-1] = row
+}
+
+// AddChild attaches a child view to the last line added to the page
+func (p *Page) AddChild(child Page) {
+	if len(p.Lines) == 0 {
+		return
+	}
+	if p.Children == nil {
+		p.Children = map[int]Page{}
+	}
+	p.Children[len(p.Lines)-1] = child
+}
+
 // Copy makes a deep copy of this Page
 func (p Page) Copy() Page {
 	nLines := make([]string, len(p.Lines))
 	copy(nLines, p.Lines)
-	return Page{Lines: nLines}
+	var nKeys []string
+	if p.LineKeys != nil {
+		nKeys = make([]string, len(p.LineKeys))
+		copy(nKeys, p.LineKeys)
+	}
+	var nDetail []string
+	if p.Detail != nil {
+		nDetail = make([]string, len(p.Detail))
+		copy(nDetail, p.Detail)
+	}
+	var nChildren map[int]Page
+	if p.Children != nil {
+		nChildren = map[int]Page{}
+		for line, child := range p.Children {
+			nChildren[line] = child.Copy()
+		}
+	}
+	var nRows map[int]Row
+	if p.Rows != nil {
+		nRows = map[int]Row{}
+		for line, row := range p.Rows {
+			nRows[line] = row
+		}
+	}
+	var nGauge *Gauge
+	if p.Gauge != nil {
+		g := *p.Gauge
+		nGauge = &g
+	}
+	return Page{Key: p.Key, Lines: nLines, LineKeys: nKeys, Detail: nDetail, Children: nChildren,
+		Icon: p.Icon, Rows: nRows, Gauge: nGauge}
 }
 
-// Write writes the MFD file
-func Write(mfd Display) {
-	UpdateDisplay(mfd)
+// Write shows a display on the MFD, see UpdateDisplay
+func Write(mfd Display) error {
+	return UpdateDisplay(mfd)
 }
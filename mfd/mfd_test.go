@@ -0,0 +1,288 @@
+package mfd
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Write every refresh straight away, TestWriteRate covers the coalescing
+	writeInterval = 0
+	os.Exit(m.Run())
+}
+
+var testPages = []string{"first", "second"}
+
+func testDisplay() Display {
+	first := Page{Lines: []string{"A", "B", "C", "D"}}
+	second := Page{Lines: []string{"Second"}}
+	return Display{Pages: []Page{first, second}}
+}
+
+func TestUpdateDisplayWritesVisibleLines(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Pages(FakeDevice); len(got) != 2 {
+		t.Fatalf("got %d pages, wanted 2", len(got))
+	}
+	if err := UpdateDisplay(testDisplay()); err != nil {
+		t.Fatal(err)
+	}
+	for l, want := range []string{"A", "B", "C"} {
+		if got := fake.Line(FakeDevice, 0, uint32(l)); got != want {
+			t.Errorf("line %d: got %q, wanted %q", l, got, want)
+		}
+	}
+}
+
+func TestUpdateDisplayRejectsWrongPageCount(t *testing.T) {
+	if err := InitDevice(NewFake(), []string{"first", "second", "third"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateDisplay(testDisplay()); err == nil {
+		t.Error("expected an error for a display with the wrong number of pages")
+	}
+}
+
+func TestSoftButtons(t *testing.T) {
+	fake := NewFake()
+	clicks := 0
+	if err := InitDevice(fake, testPages, func() { clicks++ }); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+
+	fake.PressButtons(FakeDevice, softButton_Down)
+	if got := fake.Line(FakeDevice, 0, 0); got != "B" {
+		t.Errorf("after scrolling down got %q, wanted %q", got, "B")
+	}
+	fake.PressButtons(FakeDevice, softButton_Up)
+	if got := fake.Line(FakeDevice, 0, 0); got != "A" {
+		t.Errorf("after scrolling up got %q, wanted %q", got, "A")
+	}
+	fake.PressButtons(FakeDevice, softButton_Select)
+	if clicks != 1 {
+		t.Errorf("got %d clicks, wanted 1", clicks)
+	}
+}
+
+func TestPageChange(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+
+	fake.TurnPage(FakeDevice, 1, true)
+	if got := fake.Line(FakeDevice, 1, 0); got != "Second" {
+		t.Errorf("got %q, wanted %q", got, "Second")
+	}
+}
+
+func TestSetLed(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	SetLed(LedFireA, LedAmber, false)
+	SetLed(LedFire, LedRed, false)
+	for p := uint32(0); p < 2; p++ {
+		if !fake.Led(FakeDevice, p, 0) || !fake.Led(FakeDevice, p, 1) || !fake.Led(FakeDevice, p, 2) {
+			t.Errorf("LEDs not lit on page %d", p)
+		}
+	}
+	SetLed(LedFireA, LedGreen, false)
+	if fake.Led(FakeDevice, 0, 1) || !fake.Led(FakeDevice, 0, 2) {
+		t.Error("fire A is not green")
+	}
+}
+
+func TestReplug(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+	SetLed(LedFire, LedRed, false)
+	fake.PressButtons(FakeDevice, softButton_Down)
+
+	fake.Unplug(FakeDevice)
+	display := testDisplay()
+	display.Pages[0].Lines[1] = "Changed"
+	if err := UpdateDisplay(display); err != nil {
+		t.Fatal(err)
+	}
+	fake.Plug(FakeDevice)
+
+	if got := fake.Pages(FakeDevice); len(got) != 2 {
+		t.Fatalf("got %d pages after replugging, wanted 2", len(got))
+	}
+	if got := fake.Line(FakeDevice, 0, 0); got != "Changed" {
+		t.Errorf("got %q after replugging, wanted the scrolled and updated line", got)
+	}
+	if !fake.Led(FakeDevice, 0, 0) {
+		t.Error("LED was not restored after replugging")
+	}
+}
+
+func TestMultipleDevices(t *testing.T) {
+	const second uintptr = 2
+	fake := NewFake()
+	fake.Plug(second)
+	AssignPages(map[string][]string{"FAKE-2": {"second"}})
+	defer AssignPages(map[string][]string{})
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+
+	if got := fake.Pages(second); len(got) != 1 {
+		t.Fatalf("got %d pages on the second device, wanted 1", len(got))
+	}
+	if got := fake.Line(second, 0, 0); got != "Second" {
+		t.Errorf("second device shows %q, wanted %q", got, "Second")
+	}
+
+	// Scrolling one device leaves the other alone
+	fake.PressButtons(FakeDevice, softButton_Down)
+	if got := fake.Line(FakeDevice, 0, 0); got != "B" {
+		t.Errorf("first device shows %q after scrolling, wanted %q", got, "B")
+	}
+	if got := fake.Line(second, 0, 0); got != "Second" {
+		t.Errorf("second device shows %q after scrolling the first, wanted %q", got, "Second")
+	}
+}
+
+func TestWritesOnlyChangedLines(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+	written := len(fake.Writes(FakeDevice))
+
+	UpdateDisplay(testDisplay())
+	if got := fake.Writes(FakeDevice)[written:]; len(got) != 0 {
+		t.Errorf("identical display wrote %v", got)
+	}
+
+	display := testDisplay()
+	display.Pages[0].Lines[1] = "X"
+	UpdateDisplay(display)
+	got := fake.Writes(FakeDevice)[written:]
+	if want := (FakeWrite{Page: 0, Line: 1, Text: "X"}); len(got) != 1 || got[0] != want {
+		t.Errorf("got writes %v, wanted only %v", got, want)
+	}
+}
+
+func TestWriteRate(t *testing.T) {
+	writeInterval = 50 * time.Millisecond
+	defer func() { writeInterval = 0 }()
+
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	written := len(fake.Writes(FakeDevice))
+
+	for i := 0; i < 10; i++ {
+		display := testDisplay()
+		display.Pages[0].Lines[0] = string(rune('0' + i))
+		UpdateDisplay(display)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	if got := fake.Line(FakeDevice, 0, 0); got != "9" {
+		t.Errorf("got %q after the burst, wanted the last update", got)
+	}
+	// A single coalesced write of the three lines
+	if got := fake.Writes(FakeDevice)[written:]; len(got) != 3 {
+		t.Errorf("burst caused %d writes, wanted 3: %v", len(got), got)
+	}
+}
+
+func TestDynamicPages(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, []string{"first", "docked", "second"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	first := Page{Key: "first", Lines: []string{"A", "B", "C", "D"}}
+	docked := Page{Key: "docked", Lines: []string{"Docked"}}
+	second := Page{Key: "second", Lines: []string{"Second"}}
+	if err := UpdateDisplay(Display{Pages: []Page{first, second}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Pages(FakeDevice); !slices.Equal(got, []uint32{0, 2}) {
+		t.Fatalf("got pages %v, wanted the docked page removed", got)
+	}
+	fake.TurnPage(FakeDevice, 2, true)
+	fake.PressButtons(FakeDevice, softButton_Down)
+
+	// The page appears in its place, the current page and scroll positions stay with their pages
+	if err := UpdateDisplay(Display{Pages: []Page{first, docked, second}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Pages(FakeDevice); !slices.Equal(got, []uint32{0, 1, 2}) {
+		t.Fatalf("got pages %v after docking", got)
+	}
+	if got := fake.Line(FakeDevice, 2, 0); got != "Second" {
+		t.Errorf("second page shows %q, wanted %q", got, "Second")
+	}
+	fake.TurnPage(FakeDevice, 1, true)
+	if got := fake.Line(FakeDevice, 1, 0); got != "Docked" {
+		t.Errorf("docked page shows %q, wanted %q", got, "Docked")
+	}
+
+	// Removing the current page moves to the page that took its place
+	if err := UpdateDisplay(Display{Pages: []Page{first, second}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Pages(FakeDevice); !slices.Equal(got, []uint32{0, 2}) {
+		t.Fatalf("got pages %v after undocking", got)
+	}
+	fake.TurnPage(FakeDevice, 0, true)
+	if got := fake.Line(FakeDevice, 0, 0); got != "A" {
+		t.Errorf("first page shows %q, wanted %q", got, "A")
+	}
+}
+
+func TestUpdateDisplayRejectsUnknownPages(t *testing.T) {
+	if err := InitDevice(NewFake(), testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, display := range []Display{
+		{Pages: []Page{{Key: "third"}}},
+		{Pages: []Page{{Key: "first"}, {Key: "first"}}},
+		{Pages: []Page{{Key: "first"}, {}}},
+	} {
+		if err := UpdateDisplay(display); err == nil {
+			t.Errorf("expected an error for %+v", display)
+		}
+	}
+}
+
+func TestPageRows(t *testing.T) {
+	page := NewPage()
+	page.AddRow(16, Row{Left: "CARGO:", Right: "0012/0064"})
+	page.AddRow(16, Row{Style: RowSeparator, Left: "NO CARGO"})
+	page.AddKeyedRow("gold", 16, Row{Left: "Gold", Right: "8"})
+	want := []string{"CARGO: 0012/0064", "*** NO CARGO ***", "Gold           8"}
+	if !slices.Equal(page.Lines, want) {
+		t.Errorf("got lines %q, wanted %q", page.Lines, want)
+	}
+	if got := page.LineKeys; !slices.Equal(got, []string{"", "", "gold"}) {
+		t.Errorf("got line keys %q", got)
+	}
+
+	// The rows belong to the copy
+	c := page.Copy()
+	c.Rows[2] = Row{Left: "Silver"}
+	if page.Rows[2].Left != "Gold" {
+		t.Errorf("changing the copy changed the row to %+v", page.Rows[2])
+	}
+}
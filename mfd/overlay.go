@@ -0,0 +1,161 @@
+package mfd
+
+import (
+	"slices"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Overlay is a transient screen shown on top of the pages, such as a splash or notification screen
+type Overlay struct {
+	// ID identifies the overlay. Posting an overlay with the ID of an existing one replaces it.
+	ID string `json:"id,omitempty"`
+	// Lines are the lines shown instead of the page
+	Lines []string `json:"lines,omitempty"`
+	// Priority orders the overlays. A higher priority overlay pre-empts lower ones, others wait their turn.
+	Priority int `json:"priority,omitempty"`
+	// Duration is how long the overlay is shown once its turn comes, 0 to show it until it is cleared
+	Duration time.Duration `json:"duration,omitempty"`
+	// Page is the key of the page the overlay is shown on, empty for all pages
+	Page string `json:"page,omitempty"`
+}
+
+// overlayEntry is a posted overlay and the state of its expiry timer
+type overlayEntry struct {
+	Overlay
+	started bool
+	timer   *time.Timer
+}
+
+// The posted overlays, highest priority first and in posting order within a priority
+var overlays []*overlayEntry
+
+// PostOverlay shows an overlay on the display, replacing any overlay with the same ID
+func PostOverlay(o Overlay) {
+	mu.Lock()
+	defer mu.Unlock()
+	log.Debugln("Posting overlay", o.ID, "with priority", o.Priority)
+	record(RecordedEvent{Type: EventOverlay, Overlay: &o})
+	if o.ID != "" {
+		dropOverlay(findOverlay(o.ID))
+	}
+	e := &overlayEntry{Overlay: o}
+	i := 0
+	for i < len(overlays) && overlays[i].Priority >= o.Priority {
+		i++
+	}
+	overlays = slices.Insert(overlays, i, e)
+	startOverlays()
+	refreshAll()
+}
+
+// ClearOverlay removes an overlay from the display
+func ClearOverlay(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	record(RecordedEvent{Type: EventClearOverlay, Overlay: &Overlay{ID: id}})
+	if id != "" && dropOverlay(findOverlay(id)) {
+		startOverlays()
+		refreshAll()
+	}
+}
+
+// findOverlay returns the overlay with an ID, or nil. Must be called with the lock held.
+func findOverlay(id string) *overlayEntry {
+	for _, e := range overlays {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// dropOverlay removes an overlay and stops its timer. Must be called with the lock held.
+func dropOverlay(e *overlayEntry) bool {
+	for i, o := range overlays {
+		if o == e {
+			if e.timer != nil {
+				e.timer.Stop()
+			}
+			overlays = slices.Delete(overlays, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// expireOverlay removes an overlay once its duration has passed
+func expireOverlay(e *overlayEntry) {
+	mu.Lock()
+	defer mu.Unlock()
+	if dropOverlay(e) {
+		log.Debugln("Overlay", e.ID, "expired")
+		startOverlays()
+		refreshAll()
+	}
+}
+
+// startOverlays starts the clock of every overlay that has become the top overlay on any of its pages.
+// Must be called with the lock held.
+func startOverlays() {
+	for i, e := range overlays {
+		if e.started || !overlayOnTop(e, overlays[:i]) {
+			continue
+		}
+		e.started = true
+		if e.Duration > 0 {
+			e.timer = time.AfterFunc(e.Duration, func() { expireOverlay(e) })
+		}
+	}
+}
+
+// overlayOnTop reports whether none of the overlays ahead of an overlay hide it on one of its pages.
+// Must be called with the lock held.
+func overlayOnTop(e *overlayEntry, ahead []*overlayEntry) bool {
+	pages := pageNames
+	if e.Page != "" {
+		pages = []string{e.Page}
+	}
+	for _, key := range pages {
+		hidden := false
+		for _, a := range ahead {
+			if a.Page == "" || a.Page == key {
+				hidden = true
+				break
+			}
+		}
+		if !hidden {
+			return true
+		}
+	}
+	return false
+}
+
+// overlayFor returns the overlay shown on a page, or nil. Must be called with the lock held.
+func overlayFor(key string) *overlayEntry {
+	for _, e := range overlays {
+		if e.started && (e.Page == "" || e.Page == key) {
+			return e
+		}
+	}
+	return nil
+}
+
+// dismissOverlay removes the overlay shown on the current page of a device. Must be called with the lock held.
+func dismissOverlay(d *deviceState) {
+	if dropOverlay(overlayFor(d.pageKey())) {
+		startOverlays()
+		refreshAll()
+	}
+}
+
+// clearOverlays removes all overlays. Must be called with the lock held.
+func clearOverlays() {
+	for _, e := range overlays {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+	}
+	overlays = nil
+}
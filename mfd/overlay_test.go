@@ -0,0 +1,47 @@
+package mfd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverlays(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	UpdateDisplay(testDisplay())
+
+	PostOverlay(Overlay{ID: "welcome", Lines: []string{"Welcome"}, Priority: 1})
+	if got := fake.Line(FakeDevice, 0, 0); got != "Welcome" {
+		t.Errorf("got %q, wanted the overlay", got)
+	}
+
+	// A higher priority overlay pre-empts, an overlay on another page leaves this one alone
+	PostOverlay(Overlay{ID: "alert", Lines: []string{"Alert"}, Priority: 5, Duration: 30 * time.Millisecond})
+	PostOverlay(Overlay{ID: "second", Lines: []string{"Only second"}, Priority: 9, Page: "second"})
+	if got := fake.Line(FakeDevice, 0, 0); got != "Alert" {
+		t.Errorf("got %q, wanted the higher priority overlay", got)
+	}
+	// A lower priority overlay waits its turn and gets its full duration
+	PostOverlay(Overlay{ID: "later", Lines: []string{"Later"}, Priority: 3, Duration: 30 * time.Millisecond})
+
+	time.Sleep(45 * time.Millisecond)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Later" {
+		t.Errorf("got %q after the first overlay expired, wanted the queued one", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := fake.Line(FakeDevice, 0, 0); got != "Welcome" {
+		t.Errorf("got %q after the queued overlay expired", got)
+	}
+
+	ClearOverlay("welcome")
+	if got := fake.Line(FakeDevice, 0, 0); got != "A" {
+		t.Errorf("got %q after clearing the overlay, wanted the page", got)
+	}
+	fake.TurnPage(FakeDevice, 1, true)
+	if got := fake.Line(FakeDevice, 1, 0); got != "Only second" {
+		t.Errorf("got %q on the second page, wanted its overlay", got)
+	}
+}
@@ -0,0 +1,527 @@
+package mfd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProcessVersion is the version of the display helper protocol spoken by Process
+const ProcessVersion = 1
+
+// ProcessDevice is the device handle reported by a Process backend
+const ProcessDevice uintptr = 1
+
+// Types of ProcessMessage
+const (
+	MessageHello   = "hello"
+	MessageFrame   = "frame"
+	MessageButtons = "buttons"
+	MessagePage    = "page"
+)
+
+// ErrProcessVersion is returned when a display helper speaks another version of the protocol
+var ErrProcessVersion = errors.New("unsupported display helper protocol version")
+
+var (
+	// How long a display helper has to answer the handshake
+	handshakeTimeout = 5 * time.Second
+	// How long a display helper has to exit once its input is closed, before it is killed
+	stopTimeout = 2 * time.Second
+	// How long to wait before starting a crashed display helper again, doubling up to maxRestartDelay
+	// while it can't be started
+	restartDelay    = time.Second
+	maxRestartDelay = time.Minute
+)
+
+// ProcessMessage is a message of the display helper protocol
+type ProcessMessage struct {
+	Type string `json:"type"`
+	// Version is the protocol version, in hello messages
+	Version int `json:"version,omitempty"`
+	// Name identifies the display of the helper, in its hello message. It is reported as the device serial number.
+	Name string `json:"name,omitempty"`
+	// Geometry is the size of the display of the helper, in its hello message
+	Geometry *Geometry `json:"geometry,omitempty"`
+	// Frame is what the display shows, in frame messages
+	Frame *ProcessFrame `json:"frame,omitempty"`
+	// Buttons are the soft buttons held down, in buttons messages
+	Buttons uint32 `json:"buttons,omitempty"`
+	// Page is the page to show, counting from 0, in page messages
+	Page int `json:"page,omitempty"`
+}
+
+// ProcessFrame is what the display of a helper shows
+type ProcessFrame struct {
+	// PageCount is the number of pages and CurrentPage the page shown, counting from 0
+	PageCount   int    `json:"pageCount"`
+	CurrentPage uint32 `json:"page"`
+	// Lines are the lines of the page shown, as many as the display has
+	Lines []string `json:"lines"`
+	Width int      `json:"width"`
+}
+
+// Process is a Backend that runs an external display helper program, so that drivers for other hardware
+// can be written in any language. Messages are JSON objects, one per line, on the standard input and
+// output of the helper. Its standard error is logged.
+//
+// The host starts with {"type":"hello","version":1}. The helper answers within 5 seconds with
+// {"type":"hello","version":1,"name":"...","geometry":{"width":20,"lines":4}}, where the name and geometry
+// are optional and default to the program name and the X52 Pro MFD. A helper speaking another version is stopped.
+//
+// The host then sends {"type":"frame","frame":{...}} every time the display changes. The frame holds the
+// page shown and the page count, both counting from 0 like the page messages, the display width and the
+// lines of the page shown. Frames may be skipped when the helper falls behind.
+//
+// The helper sends {"type":"buttons","buttons":<mask>} when the soft buttons held down change, where 1 is
+// select, 2 up and 4 down, and {"type":"page","page":<n>} to show another page. Unknown messages are ignored.
+//
+// The helper exits once its standard input is closed. A helper that exits on its own is started again.
+type Process struct {
+	mu sync.Mutex
+
+	name string
+	args []string
+
+	helper     *helper
+	helperName string
+	geometry   Geometry
+
+	pages       []uint32
+	currentPage int
+	lines       map[uint32][]string
+
+	// The latest frame not yet sent to the helper
+	pending []byte
+	wake    chan struct{}
+
+	onDevice DeviceChangeFunc
+	onPage   PageChangeFunc
+	onButton SoftButtonFunc
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// helper is a running display helper program
+type helper struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *io.PipeWriter
+	// Closed once the program has exited
+	exited chan struct{}
+}
+
+// NewProcess returns a Process backend running the display helper program name with the given arguments
+func NewProcess(name string, args ...string) *Process {
+	return &Process{
+		name:     name,
+		args:     args,
+		geometry: X52Pro,
+		lines:    map[uint32][]string{},
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// Initialize implements Backend. The helper is started and has to answer the handshake.
+func (p *Process) Initialize() error {
+	h, messages, err := p.start()
+	if err != nil {
+		return err
+	}
+	go p.supervise(h, messages)
+	go p.writeFrames()
+	return nil
+}
+
+// Deinitialize implements Backend. The helper is asked to exit, and killed if it doesn't.
+func (p *Process) Deinitialize() error {
+	p.quit()
+	p.mu.Lock()
+	h := p.helper
+	p.mu.Unlock()
+	if h == nil {
+		return nil
+	}
+	h.stdin.Close()
+	select {
+	case <-h.exited:
+	case <-time.After(stopTimeout):
+		log.Warnf("Display helper %s didn't exit, killing it", p.name)
+		h.cmd.Process.Kill()
+		<-h.exited
+	}
+	return nil
+}
+
+// RegisterDeviceCallback implements Backend. The device is removed while the helper is restarted.
+func (p *Process) RegisterDeviceCallback(fn DeviceChangeFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onDevice = fn
+	return nil
+}
+
+// Enumerate implements Backend
+func (p *Process) Enumerate(fn func(device uintptr)) error {
+	p.mu.Lock()
+	running := p.helper != nil
+	p.mu.Unlock()
+	if running {
+		fn(ProcessDevice)
+	}
+	return nil
+}
+
+// Geometry implements GeometryBackend
+func (p *Process) Geometry(device uintptr) Geometry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.geometry
+}
+
+// GetSerialNumber implements Backend
+func (p *Process) GetSerialNumber(device uintptr) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.helperName, nil
+}
+
+// RegisterPageCallback implements Backend
+func (p *Process) RegisterPageCallback(device uintptr, fn PageChangeFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onPage = fn
+	return nil
+}
+
+// RegisterSoftButtonCallback implements Backend
+func (p *Process) RegisterSoftButtonCallback(device uintptr, fn SoftButtonFunc) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onButton = fn
+	return nil
+}
+
+// AddPage implements Backend
+func (p *Process) AddPage(device uintptr, page uint32, active bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pages = append(p.pages, page)
+	if active {
+		p.currentPage = len(p.pages) - 1
+	}
+	p.queueFrame()
+	return nil
+}
+
+// RemovePage implements Backend
+func (p *Process) RemovePage(device uintptr, page uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := slices.Index(p.pages, page)
+	if i < 0 {
+		return fmt.Errorf("unknown page %d", page)
+	}
+	p.pages = slices.Delete(p.pages, i, i+1)
+	delete(p.lines, page)
+	if p.currentPage > i || p.currentPage >= len(p.pages) {
+		p.currentPage = max(p.currentPage-1, 0)
+	}
+	p.queueFrame()
+	return nil
+}
+
+// SetString implements Backend
+func (p *Process) SetString(device uintptr, page, line uint32, text string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if line >= uint32(p.geometry.Lines) {
+		return fmt.Errorf("line %d out of range", line)
+	}
+	lines := p.lines[page]
+	if lines == nil {
+		lines = make([]string, p.geometry.Lines)
+		p.lines[page] = lines
+	}
+	lines[line] = text
+	if len(p.pages) > 0 && p.pages[p.currentPage] == page {
+		p.queueFrame()
+	}
+	return nil
+}
+
+// SetPage implements PageBackend
+func (p *Process) SetPage(device uintptr, page uint32) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := slices.Index(p.pages, page)
+	if i < 0 {
+		return fmt.Errorf("unknown page %d", page)
+	}
+	p.currentPage = i
+	p.queueFrame()
+	return nil
+}
+
+// SetLed implements Backend. LEDs are not part of the protocol.
+func (p *Process) SetLed(device uintptr, page, led uint32, on bool) error {
+	return nil
+}
+
+// start runs the helper and makes the handshake. The messages of the helper after its hello are
+// sent to the returned channel, which is closed once its output ends.
+func (p *Process) start() (*helper, <-chan ProcessMessage, error) {
+	cmd := exec.Command(p.name, p.args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stderr := log.StandardLogger().WriterLevel(log.InfoLevel)
+	cmd.Stderr = stderr
+	log.Infoln("Starting display helper", p.name)
+	if err := cmd.Start(); err != nil {
+		stderr.Close()
+		return nil, nil, fmt.Errorf("unable to start display helper %s: %w", p.name, err)
+	}
+	h := &helper{cmd: cmd, stdin: stdin, stderr: stderr, exited: make(chan struct{})}
+	messages := make(chan ProcessMessage, 16)
+	go readProcessMessages(stdout, messages)
+
+	hello, err := handshake(stdin, messages)
+	if err != nil {
+		cmd.Process.Kill()
+		h.wait(messages)
+		return nil, nil, fmt.Errorf("display helper %s: %w", p.name, err)
+	}
+	g := X52Pro
+	if hello.Geometry != nil && hello.Geometry.Width > 0 && hello.Geometry.Lines > 0 {
+		g = *hello.Geometry
+	}
+	name := hello.Name
+	if name == "" {
+		name = filepath.Base(p.name)
+	}
+	log.Infof("Display helper %s is running (%dx%d)", name, g.Width, g.Lines)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.helper = h
+	p.helperName = name
+	p.geometry = g
+	return h, messages, nil
+}
+
+// handshake greets a helper and waits for its hello
+func handshake(stdin io.Writer, messages <-chan ProcessMessage) (ProcessMessage, error) {
+	if err := writeProcessMessage(stdin, ProcessMessage{Type: MessageHello, Version: ProcessVersion}); err != nil {
+		return ProcessMessage{}, err
+	}
+	select {
+	case hello, ok := <-messages:
+		if !ok {
+			return hello, errors.New("exited during the handshake")
+		}
+		if hello.Type != MessageHello {
+			return hello, fmt.Errorf("sent %q instead of hello", hello.Type)
+		}
+		if hello.Version != ProcessVersion {
+			return hello, fmt.Errorf("%w %d", ErrProcessVersion, hello.Version)
+		}
+		return hello, nil
+	case <-time.After(handshakeTimeout):
+		return ProcessMessage{}, errors.New("didn't answer the handshake")
+	}
+}
+
+// wait waits for the helper to exit once its output has been read to the end
+func (h *helper) wait(messages <-chan ProcessMessage) error {
+	for range messages {
+	}
+	err := h.cmd.Wait()
+	h.stderr.Close()
+	close(h.exited)
+	return err
+}
+
+// supervise handles the messages of the helper and starts it again whenever it exits
+func (p *Process) supervise(h *helper, messages <-chan ProcessMessage) {
+	for {
+		for m := range messages {
+			p.handle(m)
+		}
+		err := h.wait(messages)
+		if p.stopped() {
+			return
+		}
+		log.Warnf("Display helper %s exited (%v). Starting it again.", p.name, err)
+		p.disconnect()
+
+		delay := restartDelay
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-time.After(delay):
+			}
+			h, messages, err = p.start()
+			if err == nil {
+				break
+			}
+			log.Warnln("Unable to restart display helper:", err)
+			if errors.Is(err, ErrProcessVersion) {
+				return
+			}
+			delay = min(delay*2, maxRestartDelay)
+		}
+		if p.stopped() {
+			// Deinitialize may have missed the new helper
+			h.stdin.Close()
+			h.cmd.Process.Kill()
+			h.wait(messages)
+			return
+		}
+		p.mu.Lock()
+		fn := p.onDevice
+		p.mu.Unlock()
+		if fn != nil {
+			fn(ProcessDevice, true)
+		}
+	}
+}
+
+// disconnect forgets the pages of a helper that exited and tells the device callback that its device is gone
+func (p *Process) disconnect() {
+	p.mu.Lock()
+	p.helper = nil
+	p.pages = nil
+	p.currentPage = 0
+	p.lines = map[uint32][]string{}
+	p.pending = nil
+	fn := p.onDevice
+	p.mu.Unlock()
+	if fn != nil {
+		fn(ProcessDevice, false)
+	}
+}
+
+// handle acts on a message from the helper
+func (p *Process) handle(m ProcessMessage) {
+	switch m.Type {
+	case MessageButtons:
+		p.mu.Lock()
+		fn := p.onButton
+		p.mu.Unlock()
+		if fn != nil {
+			fn(ProcessDevice, m.Buttons&(softButton_Select|softButton_Up|softButton_Down))
+		}
+	case MessagePage:
+		p.mu.Lock()
+		if m.Page < 0 || m.Page >= len(p.pages) {
+			p.mu.Unlock()
+			log.Debugln("Display helper asked for unknown page", m.Page)
+			return
+		}
+		p.currentPage = m.Page
+		page := p.pages[p.currentPage]
+		fn := p.onPage
+		p.queueFrame()
+		p.mu.Unlock()
+		if fn != nil {
+			fn(ProcessDevice, page, true)
+		}
+	default:
+		log.Debugln("Unknown message from display helper:", m.Type)
+	}
+}
+
+// queueFrame queues the current page to be sent to the helper. Must be called with the lock held.
+func (p *Process) queueFrame() {
+	frame := ProcessFrame{PageCount: len(p.pages), CurrentPage: uint32(p.currentPage), Width: p.geometry.Width}
+	frame.Lines = make([]string, p.geometry.Lines)
+	if len(p.pages) > 0 {
+		copy(frame.Lines, p.lines[p.pages[p.currentPage]])
+	}
+	data, err := json.Marshal(ProcessMessage{Type: MessageFrame, Frame: &frame})
+	if err != nil {
+		log.Warnln("Unable to encode display frame:", err)
+		return
+	}
+	p.pending = append(data, '\n')
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// writeFrames sends the queued frames to the helper, skipping any that were replaced before their turn came
+func (p *Process) writeFrames() {
+	for {
+		select {
+		case <-p.wake:
+		case <-p.done:
+			return
+		}
+		p.mu.Lock()
+		data, h := p.pending, p.helper
+		p.pending = nil
+		p.mu.Unlock()
+		if data == nil || h == nil {
+			continue
+		}
+		if _, err := h.stdin.Write(data); err != nil {
+			log.Debugln("Unable to send frame to display helper:", err)
+		}
+	}
+}
+
+// readProcessMessages decodes the messages of a helper until its output ends
+func readProcessMessages(r io.Reader, messages chan<- ProcessMessage) {
+	defer close(messages)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var m ProcessMessage
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			log.Debugln("Invalid message from display helper:", err)
+			continue
+		}
+		log.Traceln("Display helper sent", m.Type)
+		messages <- m
+	}
+}
+
+func writeProcessMessage(w io.Writer, m ProcessMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (p *Process) stopped() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Process) quit() {
+	p.doneOnce.Do(func() { close(p.done) })
+}
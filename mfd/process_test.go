@@ -0,0 +1,114 @@
+package mfd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProcessHelper is the display helper run by the Process tests. It logs the messages it receives,
+// scrolls down and turns the page once it sees the first page, and crashes the first time it shows the second.
+func TestProcessHelper(t *testing.T) {
+	if os.Getenv("MFD_DISPLAY_HELPER") == "" {
+		t.Skip("only run as a display helper")
+	}
+	dir := os.Getenv("MFD_DISPLAY_HELPER")
+	received, err := os.OpenFile(filepath.Join(dir, "received"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		os.Exit(2)
+	}
+	send := func(m string) { fmt.Println(m) }
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fmt.Fprintln(received, scanner.Text())
+		var m ProcessMessage
+		json.Unmarshal(scanner.Bytes(), &m)
+		switch {
+		case m.Type == MessageHello:
+			send(fmt.Sprintf(`{"type":"hello","version":%s,"name":"deck","geometry":{"width":20,"lines":4}}`, os.Getenv("MFD_DISPLAY_HELPER_VERSION")))
+		case m.Type != MessageFrame:
+		case m.Frame.Lines[0] == "A":
+			send(`{"type":"buttons","buttons":4}`)
+			send(`{"type":"buttons"}`)
+		case m.Frame.Lines[0] == "B" && m.Frame.CurrentPage == 0:
+			send(`{"type":"page","page":1}`)
+		case m.Frame.Lines[0] == "Second":
+			if _, err := os.Stat(filepath.Join(dir, "crashed")); err != nil {
+				os.WriteFile(filepath.Join(dir, "crashed"), nil, 0o644)
+				fmt.Fprintln(os.Stderr, "crashing on purpose")
+				os.Exit(3)
+			}
+		}
+	}
+	os.Exit(0)
+}
+
+// startHelper sets up a Process backend running TestProcessHelper, which writes what it receives to the returned file
+func startHelper(t *testing.T, version int) (*Process, string) {
+	dir := t.TempDir()
+	t.Setenv("MFD_DISPLAY_HELPER", dir)
+	t.Setenv("MFD_DISPLAY_HELPER_VERSION", fmt.Sprint(version))
+	delay := restartDelay
+	restartDelay = 10 * time.Millisecond
+	t.Cleanup(func() { restartDelay = delay })
+	return NewProcess(os.Args[0], "-test.run=^TestProcessHelper$"), filepath.Join(dir, "received")
+}
+
+// waitForReceived waits until the helper received a message containing each of want, in order
+func waitForReceived(t *testing.T, path string, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		data, _ := os.ReadFile(path)
+		rest, missing := string(data), ""
+		for _, w := range want {
+			i := strings.Index(rest, w)
+			if i < 0 {
+				missing = w
+				break
+			}
+			rest = rest[i+len(w):]
+		}
+		if missing == "" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("helper never received %q, got:\n%s", missing, data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	p, received := startHelper(t, ProcessVersion)
+	if err := InitDevice(p, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	if got, want := LayoutGeometry(), (Geometry{Width: 20, Lines: 4}); got != want {
+		t.Errorf("got layout %+v, wanted %+v", got, want)
+	}
+	UpdateDisplay(testDisplay())
+
+	// Scrolled down, turned the page, crashed and was started again on the same page
+	waitForReceived(t, received,
+		`{"type":"hello","version":1}`,
+		`"lines":["A","B","C","D"]`,
+		`"lines":["B","C","D",""]`,
+		`"lines":["Second","","",""]`,
+		`{"type":"hello","version":1}`,
+		`"page":1,"lines":["Second","","",""]`)
+}
+
+func TestProcessVersion(t *testing.T) {
+	p, _ := startHelper(t, ProcessVersion+1)
+	if err := InitDevice(p, testPages, nil); !errors.Is(err, ErrProcessVersion) {
+		t.Errorf("got %v, wanted an unsupported version", err)
+	}
+}
@@ -0,0 +1,231 @@
+package mfd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The types of recorded events
+const (
+	// EventInit starts a recording with the pages, gestures and marquee in use
+	EventInit = "init"
+	// EventDisplay is a display update
+	EventDisplay = "display"
+	// EventPage is a page change on a device
+	EventPage = "page"
+	// EventButtons is a soft button change on a device
+	EventButtons = "buttons"
+	// EventOverlay is a posted overlay
+	EventOverlay = "overlay"
+	// EventClearOverlay is a cleared overlay
+	EventClearOverlay = "clearoverlay"
+)
+
+// RecordedEvent is a single line of a display recording
+type RecordedEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// The page keys passed to InitDevice, the gestures and the marquee, for init events
+	Pages    []string  `json:"pages,omitempty"`
+	Gestures *Gestures `json:"gestures,omitempty"`
+	Marquee  *Marquee  `json:"marquee,omitempty"`
+	// The display, for display events
+	Display *Display `json:"display,omitempty"`
+	// The serial number of the device, for page and button events
+	Serial string `json:"serial,omitempty"`
+	// The key of the page and whether it is active, for page events
+	Page   string `json:"page,omitempty"`
+	Active bool   `json:"active,omitempty"`
+	// The soft buttons held down, for button events
+	Buttons uint32 `json:"buttons,omitempty"`
+	// The overlay, for overlay events. Only the ID is set for cleared overlays.
+	Overlay *Overlay `json:"overlay,omitempty"`
+}
+
+// The encoder writing the recording, nil while not recording
+var recorder *json.Encoder
+
+// StartRecording writes every display update, page change, soft button change and overlay to w
+// as a line of JSON, starting with the current state. Call it after InitDevice, SetGestures and SetMarquee.
+func StartRecording(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	recorder = json.NewEncoder(w)
+	log.Infoln("Recording the display")
+	record(RecordedEvent{
+		Type:     EventInit,
+		Pages:    pageNames,
+		Gestures: &Gestures{LongPress: longPressTime, DoubleClick: doubleClickTime, Bindings: bindings},
+		Marquee:  marquee,
+	})
+	display := currentDisplay
+	record(RecordedEvent{Type: EventDisplay, Display: &display})
+	for _, handle := range deviceOrder {
+		d := devices[handle]
+		record(RecordedEvent{Type: EventPage, Serial: d.serial, Page: d.pageKey(), Active: d.pageActive})
+	}
+	for _, e := range overlays {
+		record(RecordedEvent{Type: EventOverlay, Overlay: &e.Overlay})
+	}
+}
+
+// StopRecording stops writing the recording. The writer passed to StartRecording may be closed afterwards.
+func StopRecording() {
+	mu.Lock()
+	defer mu.Unlock()
+	recorder = nil
+}
+
+// record writes an event to the recording, if one is running. Must be called with the lock held.
+func record(e RecordedEvent) {
+	if recorder == nil {
+		return
+	}
+	e.Time = time.Now()
+	if err := recorder.Encode(e); err != nil {
+		log.Warnln("Unable to record the display, stopping the recording:", err)
+		recorder = nil
+	}
+}
+
+// Replay plays a recording into a backend. The recorded times between events are divided by speed,
+// a speed of 0 plays the events without waiting. Soft button changes are the exception: at any speed they
+// are as far apart as recorded, up to the gesture timings, so long presses and double clicks are detected
+// as they were. Application actions bound to gestures are not run.
+// Replay calls InitDevice, and DeInitDevice should be called once the replayed display is no longer needed.
+func Replay(r io.Reader, b Backend, speed float64) error {
+	dec := json.NewDecoder(r)
+	var last time.Time
+	// When the last soft button change was recorded and when it was replayed
+	var lastButtons, replayedButtons time.Time
+	for n := 1; ; n++ {
+		var e RecordedEvent
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read event %d of the recording: %w", n, err)
+		}
+		if n == 1 && e.Type != EventInit {
+			return fmt.Errorf("the recording starts with a %s event instead of %s", e.Type, EventInit)
+		}
+		if speed > 0 && !last.IsZero() && e.Time.After(last) {
+			time.Sleep(time.Duration(float64(e.Time.Sub(last)) / speed))
+		}
+		last = e.Time
+		if e.Type == EventButtons && !lastButtons.IsZero() {
+			mu.Lock()
+			gap := gestureGap(e.Time.Sub(lastButtons))
+			mu.Unlock()
+			time.Sleep(gap - time.Since(replayedButtons))
+		}
+		if err := replayEvent(b, e, n == 1); err != nil {
+			return fmt.Errorf("unable to replay event %d of the recording: %w", n, err)
+		}
+		if e.Type == EventButtons {
+			lastButtons, replayedButtons = e.Time, time.Now()
+		}
+	}
+}
+
+// gestureGap returns how far apart to replay two soft button changes that were recorded gap apart.
+// Longer gaps than the gesture timings are detected the same, so they are shortened to just past them.
+// Must be called with the lock held.
+func gestureGap(gap time.Duration) time.Duration {
+	// Lets the gesture timers fire before the next change
+	const margin = 50 * time.Millisecond
+	return min(gap, max(longPressTime, doubleClickTime)+margin)
+}
+
+func replayEvent(b Backend, e RecordedEvent, first bool) error {
+	log.Traceln("Replaying", e.Type, "event from", e.Time)
+	switch e.Type {
+	case EventInit:
+		if !first {
+			// A restarted recording continues the same display
+			return nil
+		}
+		if err := InitDevice(b, e.Pages, nil); err != nil {
+			return err
+		}
+		if e.Gestures != nil {
+			// Application actions are replaced by ones that do nothing, so the bindings stay valid
+			for _, action := range e.Gestures.Bindings {
+				mu.Lock()
+				known := knownAction(action)
+				mu.Unlock()
+				if !known {
+					RegisterAction(action, func() {})
+				}
+			}
+			if err := SetGestures(*e.Gestures); err != nil {
+				return err
+			}
+		}
+		if e.Marquee != nil {
+			SetMarquee(*e.Marquee)
+		}
+	case EventDisplay:
+		if e.Display == nil {
+			return fmt.Errorf("display event without a display")
+		}
+		if err := UpdateDisplay(*e.Display); err != nil {
+			log.Warnln("Replayed display update failed:", err)
+		}
+	case EventPage:
+		replayPage(e.Serial, e.Page, e.Active)
+	case EventButtons:
+		mu.Lock()
+		run := handleButtons(replayDevice(e.Serial), e.Buttons)
+		mu.Unlock()
+		runActions(run)
+	case EventOverlay:
+		if e.Overlay == nil {
+			return fmt.Errorf("overlay event without an overlay")
+		}
+		PostOverlay(*e.Overlay)
+	case EventClearOverlay:
+		if e.Overlay != nil {
+			ClearOverlay(e.Overlay.ID)
+		}
+	default:
+		log.Warnln("Skipping unknown recorded event", e.Type)
+	}
+	return nil
+}
+
+// replayDevice returns the device with a serial number, or the primary device when it is not attached.
+// Must be called with the lock held.
+func replayDevice(serial string) *deviceState {
+	for _, handle := range deviceOrder {
+		if devices[handle].serial == serial {
+			return devices[handle]
+		}
+	}
+	return primaryDevice()
+}
+
+// replayPage shows a recorded page change on the device and, where the backend can, on the backend too
+func replayPage(serial, key string, active bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	d := replayDevice(serial)
+	current := slices.Index(d.pages, key)
+	if current < 0 {
+		log.Debugln("Skipping the change to missing page", key)
+		return
+	}
+	if pb, ok := backend.(PageBackend); ok && d.handle > 0 {
+		if err := pb.SetPage(d.handle, d.pageIDs[key]); err != nil {
+			log.Warnln("Unable to show page", key, err)
+		}
+	}
+	showPage(d, current, active)
+}
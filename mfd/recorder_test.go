@@ -0,0 +1,75 @@
+package mfd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	var recording bytes.Buffer
+	StartRecording(&recording)
+	UpdateDisplay(testDisplay())
+	fake.PressButtons(FakeDevice, softButton_Down)
+	fake.TurnPage(FakeDevice, 1, true)
+	PostOverlay(Overlay{ID: "note", Lines: []string{"Note"}, Page: "second"})
+	StopRecording()
+	UpdateDisplay(Display{Pages: []Page{{Lines: []string{"Not recorded"}}, {Lines: []string{"Not recorded"}}}})
+
+	var types []string
+	for _, line := range strings.Split(strings.TrimSpace(recording.String()), "\n") {
+		var e RecordedEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, e.Type)
+	}
+	want := []string{EventInit, EventDisplay, EventPage, EventDisplay, EventButtons, EventButtons, EventPage, EventOverlay}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("recorded %v, wanted %v", types, want)
+	}
+
+	replay := NewFake()
+	if err := Replay(&recording, replay, 0); err != nil {
+		t.Fatal(err)
+	}
+	for l, want := range []string{"B", "C", "D"} {
+		if got := replay.Line(FakeDevice, 0, uint32(l)); got != want {
+			t.Errorf("replayed line %d: got %q, wanted %q", l, got, want)
+		}
+	}
+	if got := replay.Line(FakeDevice, 1, 0); got != "Note" {
+		t.Errorf("replayed overlay: got %q, wanted %q", got, "Note")
+	}
+}
+
+func TestReplayKeepsGestureTimings(t *testing.T) {
+	defer SetGestures(Gestures{LongPress: 600 * time.Millisecond, DoubleClick: 300 * time.Millisecond})
+	recording := `{"time":"2025-07-12T10:00:00Z","type":"init","pages":["first","second"],` +
+		`"gestures":{"longPress":100000000,"doubleClick":50000000,"bindings":{"longpress":"detail"}}}
+{"time":"2025-07-12T10:00:00Z","type":"display","display":{"pages":[{"key":"first","lines":["First"],"detail":["All about first"]},{"key":"second","lines":["Second"]}]}}
+{"time":"2025-07-12T10:00:01Z","type":"buttons","serial":"FAKE-1","buttons":1}
+{"time":"2025-07-12T10:00:02Z","type":"buttons","serial":"FAKE-1"}`
+	replay := NewFake()
+	if err := Replay(strings.NewReader(recording), replay, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	// Held down for a second, select was a long press even without waiting between the other events
+	if got := replay.Line(FakeDevice, 0, 0); got != "All about first" {
+		t.Errorf("showing %q after replaying a long press, wanted the detail view", got)
+	}
+}
+
+func TestReplayNeedsInit(t *testing.T) {
+	recording := `{"time":"2025-07-12T10:00:00Z","type":"display","display":{"pages":[]}}`
+	if err := Replay(strings.NewReader(recording), NewFake(), 0); err == nil {
+		t.Error("expected an error for a recording without an init event")
+	}
+}
@@ -0,0 +1,422 @@
+package mfd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SerialDevice is the device handle reported by a Serial backend
+const SerialDevice uintptr = 1
+
+// Serial is a Backend for character LCDs, such as HD44780 20x4 modules, driven by a microcontroller
+// on a serial port. Only the page currently shown is sent, using a line based text protocol.
+//
+// Every message is a single line ending in "\n"; a "\r" before it is ignored.
+// The host sends:
+//
+//	LINE <n> <text>     show text on line n, counting from 0. The text is always exactly as wide as the
+//	                    display, one printable ASCII character per byte
+//	PAGE <i> <count>    the page shown is page i of count, counting from 1, for a page indicator
+//
+// Only lines that changed are sent. The display sends:
+//
+//	HELLO               the display (re)started, the host sends every line again
+//	UP, DOWN, SELECT    a button was pressed and released
+//	BUTTONS <mask>      the buttons held down changed: 1 is select, 2 up and 4 down, 0 once all are released.
+//	                    This allows gestures such as a long press or scrolling with select held down
+//	NEXT, PREV          turn to the next or previous page
+//
+// Unknown messages are ignored.
+type Serial struct {
+	mu sync.Mutex
+
+	name     string
+	geometry Geometry
+	port     io.ReadWriter
+	reopen   func() (io.ReadWriteCloser, error)
+
+	pages       []uint32
+	currentPage int
+	lines       map[uint32][]string
+	// The lines and page indicator the display shows, nil when they have to be sent again
+	shown     []string
+	shownPage string
+
+	onDevice DeviceChangeFunc
+	onPage   PageChangeFunc
+	onButton SoftButtonFunc
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewSerial returns a Serial backend talking to a display of the given size over port.
+// name is reported as the serial number of the device.
+func NewSerial(port io.ReadWriter, name string, g Geometry) *Serial {
+	return &Serial{
+		name:     name,
+		geometry: g,
+		port:     port,
+		lines:    map[uint32][]string{},
+		done:     make(chan struct{}),
+	}
+}
+
+// OpenSerial opens a serial port, such as COM3 or /dev/ttyUSB0, and returns a Serial backend for the display on it.
+// When the port goes away, for example because the display is unplugged, it is opened again once it is back.
+func OpenSerial(path string, baud int, g Geometry) (*Serial, error) {
+	port, err := openSerialPort(path, baud)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open serial port %s: %w", path, err)
+	}
+	s := NewSerial(port, path, g)
+	s.reopen = func() (io.ReadWriteCloser, error) { return openSerialPort(path, baud) }
+	return s, nil
+}
+
+// Done is closed when the backend stops, because it was deinitialized or the port can't be read any more
+func (s *Serial) Done() <-chan struct{} {
+	return s.done
+}
+
+// Initialize implements Backend
+func (s *Serial) Initialize() error {
+	go s.run()
+	return nil
+}
+
+// Deinitialize implements Backend. The port is closed.
+func (s *Serial) Deinitialize() error {
+	s.quit()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.port.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// RegisterDeviceCallback implements Backend. The device is removed while the port is gone.
+func (s *Serial) RegisterDeviceCallback(fn DeviceChangeFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onDevice = fn
+	return nil
+}
+
+// Enumerate implements Backend
+func (s *Serial) Enumerate(fn func(device uintptr)) error {
+	s.mu.Lock()
+	connected := s.port != nil
+	s.mu.Unlock()
+	if connected {
+		fn(SerialDevice)
+	}
+	return nil
+}
+
+// Geometry implements GeometryBackend
+func (s *Serial) Geometry(device uintptr) Geometry {
+	return s.geometry
+}
+
+// GetSerialNumber implements Backend
+func (s *Serial) GetSerialNumber(device uintptr) (string, error) {
+	return s.name, nil
+}
+
+// RegisterPageCallback implements Backend
+func (s *Serial) RegisterPageCallback(device uintptr, fn PageChangeFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onPage = fn
+	return nil
+}
+
+// RegisterSoftButtonCallback implements Backend
+func (s *Serial) RegisterSoftButtonCallback(device uintptr, fn SoftButtonFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onButton = fn
+	return nil
+}
+
+// AddPage implements Backend
+func (s *Serial) AddPage(device uintptr, page uint32, active bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages = append(s.pages, page)
+	if active {
+		s.currentPage = len(s.pages) - 1
+	}
+	return s.draw()
+}
+
+// RemovePage implements Backend
+func (s *Serial) RemovePage(device uintptr, page uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.pages, page)
+	if i < 0 {
+		return fmt.Errorf("unknown page %d", page)
+	}
+	s.pages = slices.Delete(s.pages, i, i+1)
+	delete(s.lines, page)
+	if s.currentPage > i || s.currentPage >= len(s.pages) {
+		s.currentPage = max(s.currentPage-1, 0)
+	}
+	return s.draw()
+}
+
+// SetString implements Backend
+func (s *Serial) SetString(device uintptr, page, line uint32, text string) error {
+	if line >= uint32(s.geometry.Lines) {
+		return fmt.Errorf("line %d out of range", line)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lines := s.lines[page]
+	if lines == nil {
+		lines = make([]string, s.geometry.Lines)
+		s.lines[page] = lines
+	}
+	lines[line] = text
+	if len(s.pages) == 0 || s.pages[s.currentPage] != page {
+		return nil
+	}
+	return s.draw()
+}
+
+// SetPage implements PageBackend
+func (s *Serial) SetPage(device uintptr, page uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.pages, page)
+	if i < 0 {
+		return fmt.Errorf("unknown page %d", page)
+	}
+	s.currentPage = i
+	return s.draw()
+}
+
+// SetLed implements Backend. The protocol has no LEDs.
+func (s *Serial) SetLed(device uintptr, page, led uint32, on bool) error {
+	return nil
+}
+
+// draw sends the lines of the current page that the display doesn't show yet. Must be called with the lock held.
+func (s *Serial) draw() error {
+	if s.port == nil {
+		return nil
+	}
+	var lines []string
+	if len(s.pages) > 0 {
+		lines = s.lines[s.pages[s.currentPage]]
+	}
+	if s.shown == nil {
+		s.shown = make([]string, s.geometry.Lines)
+		for l := range s.shown {
+			// Never matches a line, so every line is sent
+			s.shown[l] = "\n"
+		}
+		s.shownPage = ""
+	}
+	var b strings.Builder
+	for l := 0; l < s.geometry.Lines; l++ {
+		line := ""
+		if l < len(lines) {
+			line = lines[l]
+		}
+		text := fitWidth(line, s.geometry.Width)
+		if text != s.shown[l] {
+			fmt.Fprintf(&b, "LINE %d %s\n", l, serialText(text))
+			s.shown[l] = text
+		}
+	}
+	if page := fmt.Sprintf("%d %d", s.currentPage+1, len(s.pages)); page != s.shownPage {
+		fmt.Fprintf(&b, "PAGE %s\n", page)
+		s.shownPage = page
+	}
+	if b.Len() == 0 {
+		return nil
+	}
+	if _, err := io.WriteString(s.port, b.String()); err != nil {
+		// Whatever made it to the display is unknown now
+		s.shown = nil
+		return err
+	}
+	return nil
+}
+
+// serialText converts a line to printable ASCII, one byte per character
+func serialText(text string) string {
+	b := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < ' ':
+			b = append(b, ' ')
+		case r >= 0x7F:
+			b = append(b, '?')
+		default:
+			b = append(b, byte(r))
+		}
+	}
+	return string(b)
+}
+
+// run reads the messages from the display, opening the port again whenever it goes away
+func (s *Serial) run() {
+	defer s.quit()
+	for {
+		s.mu.Lock()
+		port := s.port
+		s.mu.Unlock()
+		err := s.readMessages(port)
+		if s.stopped() {
+			return
+		}
+		if s.reopen == nil {
+			log.Warnf("Serial display %s stopped: %v", s.name, err)
+			return
+		}
+		log.Warnf("Serial display %s was disconnected: %v. Waiting for it to come back.", s.name, err)
+		s.setPort(nil, false)
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-time.After(recoverInterval):
+			}
+			port, err := s.reopen()
+			if err != nil {
+				log.Traceln("Unable to open serial port:", err)
+				continue
+			}
+			if s.stopped() {
+				port.Close()
+				return
+			}
+			log.Infof("Serial display %s is back", s.name)
+			s.setPort(port, true)
+			break
+		}
+	}
+}
+
+// setPort switches to a new port and tells the device callback that the device was added or removed.
+// The pages are set up again when the device is added back.
+func (s *Serial) setPort(port io.ReadWriter, added bool) {
+	s.mu.Lock()
+	if c, ok := s.port.(io.Closer); ok && port == nil {
+		c.Close()
+	}
+	s.port = port
+	s.pages = nil
+	s.currentPage = 0
+	s.lines = map[uint32][]string{}
+	s.shown = nil
+	fn := s.onDevice
+	s.mu.Unlock()
+	if fn != nil {
+		fn(SerialDevice, added)
+	}
+}
+
+// readMessages handles the messages from the display until the port can't be read any more
+func (s *Serial) readMessages(port io.Reader) error {
+	scanner := bufio.NewScanner(port)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		log.Traceln("Serial display sent", fields)
+		switch strings.ToUpper(fields[0]) {
+		case "HELLO":
+			s.mu.Lock()
+			s.shown = nil
+			if err := s.draw(); err != nil {
+				log.Warnln("Unable to write to serial display:", err)
+			}
+			s.mu.Unlock()
+		case "UP":
+			s.pressButtons(softButton_Up)
+			s.pressButtons(0)
+		case "DOWN":
+			s.pressButtons(softButton_Down)
+			s.pressButtons(0)
+		case "SELECT":
+			s.pressButtons(softButton_Select)
+			s.pressButtons(0)
+		case "BUTTONS":
+			if len(fields) < 2 {
+				continue
+			}
+			mask, err := strconv.ParseUint(fields[1], 10, 32)
+			if err != nil {
+				log.Debugln("Invalid buttons from serial display:", fields[1])
+				continue
+			}
+			s.pressButtons(uint32(mask) & (softButton_Select | softButton_Up | softButton_Down))
+		case "NEXT":
+			s.turnPage(1)
+		case "PREV":
+			s.turnPage(-1)
+		default:
+			log.Debugln("Unknown message from serial display:", scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// pressButtons reports the soft buttons held down
+func (s *Serial) pressButtons(buttons uint32) {
+	s.mu.Lock()
+	fn := s.onButton
+	s.mu.Unlock()
+	if fn != nil {
+		fn(SerialDevice, buttons)
+	}
+}
+
+func (s *Serial) turnPage(delta int) {
+	s.mu.Lock()
+	if len(s.pages) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.currentPage = (s.currentPage + delta + len(s.pages)) % len(s.pages)
+	page := s.pages[s.currentPage]
+	fn := s.onPage
+	if err := s.draw(); err != nil {
+		log.Warnln("Unable to write to serial display:", err)
+	}
+	s.mu.Unlock()
+	if fn != nil {
+		fn(SerialDevice, page, true)
+	}
+}
+
+func (s *Serial) stopped() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Serial) quit() {
+	s.doneOnce.Do(func() { close(s.done) })
+}
@@ -0,0 +1,92 @@
+package mfd
+
+import (
+	"bufio"
+	"io"
+	"testing"
+	"time"
+)
+
+// serialPipe connects a Serial backend to a simulated display
+type serialPipe struct {
+	io.Reader
+	io.Writer
+}
+
+// expectMessages reads messages sent to the display until every wanted one arrived
+func expectMessages(t *testing.T, messages <-chan string, want ...string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for len(want) > 0 {
+		select {
+		case m := <-messages:
+			if m == want[0] {
+				want = want[1:]
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q", want[0])
+		}
+	}
+}
+
+// readDisplay forwards every line sent to the display
+func readDisplay(r io.Reader) <-chan string {
+	messages := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			messages <- scanner.Text()
+		}
+		close(messages)
+	}()
+	return messages
+}
+
+func TestSerial(t *testing.T) {
+	hostIn, display := io.Pipe()
+	displayIn, host := io.Pipe()
+	messages := readDisplay(displayIn)
+	s := NewSerial(serialPipe{hostIn, host}, "lcd", Geometry{Width: 20, Lines: 4})
+	clicks := 0
+	if err := InitDevice(s, testPages, func() { clicks++ }); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+
+	d := testDisplay()
+	d.Pages[0].Lines[0] = "20°C"
+	UpdateDisplay(d)
+	expectMessages(t, messages, "PAGE 1 2", "LINE 0 20degC              ", "LINE 3 D                   ")
+
+	io.WriteString(display, "NEXT\r\n")
+	expectMessages(t, messages, "PAGE 2 2", "LINE 0 Second              ")
+
+	io.WriteString(display, "PREV\nDOWN\n")
+	expectMessages(t, messages, "PAGE 1 2", "LINE 0 B                   ")
+
+	// Holding select down while scrolling isn't a click
+	io.WriteString(display, "BUTTONS 1\nBUTTONS 3\nBUTTONS 0\nSELECT\n")
+	io.WriteString(display, "HELLO\n")
+	expectMessages(t, messages, "LINE 0 B                   ", "LINE 1 C                   ", "LINE 2 D                   ", "LINE 3                     ", "PAGE 1 2")
+	mu.Lock()
+	got := clicks
+	mu.Unlock()
+	if got != 1 {
+		t.Errorf("got %d clicks, wanted 1", got)
+	}
+}
+
+func TestSerialStopsWhenPortCloses(t *testing.T) {
+	hostIn, display := io.Pipe()
+	s := NewSerial(serialPipe{hostIn, io.Discard}, "lcd", X52Pro)
+	if err := InitDevice(s, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	display.Close()
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend didn't stop")
+	}
+}
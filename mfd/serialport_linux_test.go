@@ -0,0 +1,65 @@
+package mfd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty opens a pseudo-terminal, returning the controlling side and the path of the serial port side
+func openPty(t *testing.T) (*os.File, string) {
+	t.Helper()
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("no pseudo-terminals:", err)
+	}
+	var n int
+	rc, err := ptmx.SyscallConn()
+	if err == nil {
+		rc.Control(func(fd uintptr) {
+			if err = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); err == nil {
+				n, err = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+			}
+		})
+	}
+	if err != nil {
+		ptmx.Close()
+		t.Fatal(err)
+	}
+	return ptmx, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func TestSerialPty(t *testing.T) {
+	ptmx, path := openPty(t)
+	defer ptmx.Close()
+	messages := readDisplay(ptmx)
+
+	s, err := OpenSerial(path, 115200, Geometry{Width: 20, Lines: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InitDevice(s, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	UpdateDisplay(testDisplay())
+	expectMessages(t, messages, "LINE 0 A                   ", "LINE 3 D                   ")
+
+	// The port is raw, so neither side sees its messages echoed or "\n" turned into "\r\n"
+	io.WriteString(ptmx, "NEXT\n")
+	expectMessages(t, messages, "PAGE 2 2", "LINE 0 Second              ")
+
+	// Losing the other side unplugs the display
+	ptmx.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for LayoutGeometry() != X52Pro {
+		if time.Now().After(deadline) {
+			t.Fatal("display wasn't removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
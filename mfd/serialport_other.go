@@ -0,0 +1,28 @@
+//go:build !windows && !linux
+
+package mfd
+
+import (
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+// openSerialPort opens a serial port in raw mode. The baud rate is left as it is, set it with stty beforehand.
+func openSerialPort(path string, baud int) (io.ReadWriteCloser, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(f.Fd())
+	if term.IsTerminal(fd) {
+		if _, err := term.MakeRaw(fd); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	log.Debugf("Opened %s without setting the baud rate to %d", path, baud)
+	return f, nil
+}
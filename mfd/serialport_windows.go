@@ -0,0 +1,92 @@
+package mfd
+
+import (
+	"io"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// How long a read waits for data before checking whether the port was closed, in milliseconds.
+// Reads and writes on a port take turns, so this is also the longest a write waits.
+const serialReadTimeout = 50
+
+// DCB flags for binary mode without flow control
+const dcbBinary = 0x00000001
+
+// serialPort is an open COM port
+type serialPort struct {
+	handle windows.Handle
+	closed atomic.Bool
+}
+
+// openSerialPort opens a COM port in 8N1 mode without flow control
+func openSerialPort(path string, baud int) (io.ReadWriteCloser, error) {
+	if !strings.HasPrefix(path, `\\.\`) {
+		// COM10 and up can only be opened with the device namespace prefix
+		path = `\\.\` + path
+	}
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := windows.CreateFile(name, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	dcb := windows.DCB{
+		BaudRate: uint32(baud),
+		Flags:    dcbBinary,
+		ByteSize: 8,
+		Parity:   windows.NOPARITY,
+		StopBits: windows.ONESTOPBIT,
+	}
+	dcb.DCBlength = uint32(unsafe.Sizeof(dcb))
+	if err := windows.SetCommState(handle, &dcb); err != nil {
+		windows.CloseHandle(handle)
+		return nil, err
+	}
+	// Reads return as soon as a byte arrives, or empty after the timeout
+	timeouts := windows.CommTimeouts{
+		ReadIntervalTimeout:        ^uint32(0),
+		ReadTotalTimeoutMultiplier: ^uint32(0),
+		ReadTotalTimeoutConstant:   serialReadTimeout,
+	}
+	if err := windows.SetCommTimeouts(handle, &timeouts); err != nil {
+		windows.CloseHandle(handle)
+		return nil, err
+	}
+	return &serialPort{handle: handle}, nil
+}
+
+// Read waits for at least one byte, or the port to be closed
+func (p *serialPort) Read(b []byte) (int, error) {
+	for {
+		if p.closed.Load() {
+			return 0, io.EOF
+		}
+		var n uint32
+		if err := windows.ReadFile(p.handle, b, &n, nil); err != nil {
+			return int(n), err
+		}
+		if n > 0 {
+			return int(n), nil
+		}
+	}
+}
+
+func (p *serialPort) Write(b []byte) (int, error) {
+	var n uint32
+	err := windows.WriteFile(p.handle, b, &n, nil)
+	return int(n), err
+}
+
+// Close closes the port. A pending read returns within the read timeout.
+func (p *serialPort) Close() error {
+	if p.closed.Swap(true) {
+		return nil
+	}
+	return windows.CloseHandle(p.handle)
+}
@@ -0,0 +1,117 @@
+package mfd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Snapshot is the content of the snapshot file: the whole display and what the MFD currently shows
+type Snapshot struct {
+	Display Display `json:"display"`
+	Frame   Frame   `json:"frame"`
+}
+
+// SnapshotWriter keeps a JSON snapshot of the display in a file, for stream overlays and other tools.
+// The file is replaced as a whole, so readers never see a partly written snapshot.
+type SnapshotWriter struct {
+	path string
+	// Stops publishing frames to the writer
+	removeListener func()
+
+	mu      sync.Mutex
+	pending []byte
+	closed  bool
+
+	wake chan struct{}
+	done chan struct{}
+}
+
+// StartSnapshots writes a snapshot to path every time the display or the visible lines change.
+// The snapshot follows the same device as listeners do.
+func StartSnapshots(path string) *SnapshotWriter {
+	s := &SnapshotWriter{path: path, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go s.run()
+
+	mu.Lock()
+	s.publish(primaryDevice().frame())
+	mu.Unlock()
+	s.removeListener = AddListener(s.publish)
+	log.Infof("Writing display snapshots to %s", path)
+	return s
+}
+
+// Close stops writing snapshots once the latest one has been written
+func (s *SnapshotWriter) Close() {
+	s.removeListener()
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	<-s.done
+}
+
+// publish queues a snapshot of the current display and a frame. Called with the device state locked.
+func (s *SnapshotWriter) publish(frame Frame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	data, err := json.MarshalIndent(Snapshot{Display: currentDisplay, Frame: frame}, "", "  ")
+	if err != nil {
+		log.Warnln("Unable to encode display snapshot:", err)
+		return
+	}
+	s.pending = data
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes the queued snapshots, skipping any that were replaced before their turn came
+func (s *SnapshotWriter) run() {
+	defer close(s.done)
+	var last []byte
+	for range s.wake {
+		s.mu.Lock()
+		data, closed := s.pending, s.closed
+		s.pending = nil
+		s.mu.Unlock()
+		if data != nil && !bytes.Equal(data, last) {
+			if err := writeFileAtomic(s.path, data); err != nil {
+				log.Warnln("Unable to write display snapshot:", err)
+			} else {
+				last = data
+			}
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// writeFileAtomic replaces a file with new content by writing a temporary file next to it and renaming it
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
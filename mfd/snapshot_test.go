@@ -0,0 +1,70 @@
+package mfd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func readSnapshot(t *testing.T, path string) Snapshot {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSnapshot(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	before := len(listeners)
+	mu.Unlock()
+	dir := t.TempDir()
+	path := filepath.Join(dir, Filename)
+	snapshots := StartSnapshots(path)
+	UpdateDisplay(testDisplay())
+	fake.PressButtons(FakeDevice, softButton_Down)
+	snapshots.Close()
+
+	s := readSnapshot(t, path)
+	if len(s.Display.Pages) != 2 || s.Display.Pages[0].Key != "first" || len(s.Display.Pages[0].Lines) != 4 {
+		t.Errorf("got display %+v, wanted the whole display", s.Display)
+	}
+	if s.Frame.Key != "first" || s.Frame.Line != 1 {
+		t.Errorf("got page %q line %d, wanted page first line 1", s.Frame.Key, s.Frame.Line)
+	}
+	if want := []string{"B", "C", "D"}; !slices.Equal(s.Frame.Lines, want) {
+		t.Errorf("got visible lines %q, wanted %q", s.Frame.Lines, want)
+	}
+
+	// Only the snapshot is left, the temporary files are renamed or removed
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, wanted only the snapshot", len(entries))
+	}
+
+	// Closed writers stop listening and leave the snapshot alone
+	mu.Lock()
+	left := len(listeners)
+	mu.Unlock()
+	if left != before {
+		t.Errorf("%d listeners left after closing the writer, wanted %d", left, before)
+	}
+	UpdateDisplay(Display{Pages: []Page{{Lines: []string{"Later"}}, {Lines: []string{"Later"}}}})
+	if s := readSnapshot(t, path); s.Frame.Lines[0] != "B" {
+		t.Errorf("snapshot changed to %q after closing", s.Frame.Lines)
+	}
+}
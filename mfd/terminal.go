@@ -0,0 +1,271 @@
+package mfd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// TerminalDevice is the device handle reported by a Terminal backend
+const TerminalDevice uintptr = 1
+
+// Terminal is a Backend that simulates the X52 Pro MFD in a text terminal.
+// The arrow keys emulate the page wheel (left/right) and the scroll wheel (up/down),
+// and Enter emulates clicking the scroll wheel.
+type Terminal struct {
+	mu sync.Mutex
+
+	in       io.Reader
+	out      io.Writer
+	geometry Geometry
+
+	pages       []uint32
+	currentPage int
+	lines       map[uint32][]string
+
+	onPage   PageChangeFunc
+	onButton SoftButtonFunc
+
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// NewTerminal returns a Terminal backend the size of the X52 Pro MFD, reading keys from in and drawing to out.
+// in should be a terminal in raw mode so that key presses arrive unbuffered.
+func NewTerminal(in io.Reader, out io.Writer) *Terminal {
+	return NewTerminalSize(in, out, X52Pro)
+}
+
+// NewTerminalSize returns a Terminal backend simulating a character display of the given size
+func NewTerminalSize(in io.Reader, out io.Writer, g Geometry) *Terminal {
+	return &Terminal{
+		in:       in,
+		out:      out,
+		geometry: g,
+		lines:    map[uint32][]string{},
+		done:     make(chan struct{}),
+	}
+}
+
+// Done is closed when the user quits the simulator or the input is closed
+func (t *Terminal) Done() <-chan struct{} {
+	return t.done
+}
+
+// Initialize implements Backend
+func (t *Terminal) Initialize() error {
+	go t.readKeys()
+	return nil
+}
+
+// Deinitialize implements Backend
+func (t *Terminal) Deinitialize() error {
+	t.quit()
+	return nil
+}
+
+// RegisterDeviceCallback implements Backend. The simulated device is never removed.
+func (t *Terminal) RegisterDeviceCallback(fn DeviceChangeFunc) error {
+	return nil
+}
+
+// Enumerate implements Backend
+func (t *Terminal) Enumerate(fn func(device uintptr)) error {
+	fn(TerminalDevice)
+	return nil
+}
+
+// Geometry implements GeometryBackend
+func (t *Terminal) Geometry(device uintptr) Geometry {
+	return t.geometry
+}
+
+// GetSerialNumber implements Backend
+func (t *Terminal) GetSerialNumber(device uintptr) (string, error) {
+	return "terminal", nil
+}
+
+// RegisterPageCallback implements Backend
+func (t *Terminal) RegisterPageCallback(device uintptr, fn PageChangeFunc) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onPage = fn
+	return nil
+}
+
+// RegisterSoftButtonCallback implements Backend
+func (t *Terminal) RegisterSoftButtonCallback(device uintptr, fn SoftButtonFunc) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onButton = fn
+	return nil
+}
+
+// AddPage implements Backend
+func (t *Terminal) AddPage(device uintptr, page uint32, active bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pages = append(t.pages, page)
+	if active {
+		t.currentPage = len(t.pages) - 1
+	}
+	t.draw()
+	return nil
+}
+
+// RemovePage implements Backend
+func (t *Terminal) RemovePage(device uintptr, page uint32) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := slices.Index(t.pages, page)
+	if i < 0 {
+		return fmt.Errorf("unknown page %d", page)
+	}
+	t.pages = slices.Delete(t.pages, i, i+1)
+	delete(t.lines, page)
+	if t.currentPage > i || t.currentPage >= len(t.pages) {
+		t.currentPage = max(t.currentPage-1, 0)
+	}
+	t.draw()
+	return nil
+}
+
+// SetString implements Backend
+func (t *Terminal) SetString(device uintptr, page, line uint32, text string) error {
+	if line >= uint32(t.geometry.Lines) {
+		return fmt.Errorf("line %d out of range", line)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.lines[page]
+	if lines == nil {
+		lines = make([]string, t.geometry.Lines)
+		t.lines[page] = lines
+	}
+	lines[line] = text
+	t.draw()
+	return nil
+}
+
+// SetPage implements PageBackend
+func (t *Terminal) SetPage(device uintptr, page uint32) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i := slices.Index(t.pages, page)
+	if i < 0 {
+		return fmt.Errorf("unknown page %d", page)
+	}
+	t.currentPage = i
+	t.draw()
+	return nil
+}
+
+// SetLed implements Backend. The simulator has no LEDs.
+func (t *Terminal) SetLed(device uintptr, page, led uint32, on bool) error {
+	return nil
+}
+
+// draw redraws the whole simulated MFD. Must be called with the lock held.
+func (t *Terminal) draw() {
+	var b strings.Builder
+	// Move the cursor home and clear the screen
+	b.WriteString("\x1b[H\x1b[2J")
+	border := "+" + strings.Repeat("-", t.geometry.Width) + "+\r\n"
+	b.WriteString(border)
+	var lines []string
+	if len(t.pages) > 0 {
+		lines = t.lines[t.pages[t.currentPage]]
+	}
+	for l := 0; l < t.geometry.Lines; l++ {
+		line := ""
+		if l < len(lines) {
+			line = lines[l]
+		}
+		b.WriteString("|" + fitWidth(line, t.geometry.Width) + "|\r\n")
+	}
+	b.WriteString(border)
+	fmt.Fprintf(&b, " Page %d/%d\r\n", t.currentPage+1, len(t.pages))
+	b.WriteString(" Left/Right: page  Up/Down: scroll  Enter: select  q: quit\r\n")
+	io.WriteString(t.out, b.String())
+}
+
+// readKeys translates key presses into page and soft button events
+func (t *Terminal) readKeys() {
+	defer t.quit()
+	r := bufio.NewReader(t.in)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch c {
+		case 'q', 0x03: // q or Ctrl-C
+			return
+		case '\r', '\n':
+			t.pressButtons(softButton_Select)
+		case 0x1b:
+			// Arrow keys arrive as the escape sequence ESC [ A-D
+			if next, err := r.ReadByte(); err != nil || next != '[' {
+				continue
+			}
+			key, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			switch key {
+			case 'A':
+				t.pressButtons(softButton_Up)
+			case 'B':
+				t.pressButtons(softButton_Down)
+			case 'C':
+				t.turnPage(1)
+			case 'D':
+				t.turnPage(-1)
+			}
+		}
+	}
+}
+
+// pressButtons presses and releases soft buttons
+func (t *Terminal) pressButtons(buttons uint32) {
+	t.mu.Lock()
+	fn := t.onButton
+	t.mu.Unlock()
+	if fn != nil {
+		fn(TerminalDevice, buttons)
+		fn(TerminalDevice, 0)
+	}
+}
+
+func (t *Terminal) turnPage(delta int) {
+	t.mu.Lock()
+	if len(t.pages) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	t.currentPage = (t.currentPage + delta + len(t.pages)) % len(t.pages)
+	page := t.pages[t.currentPage]
+	fn := t.onPage
+	t.draw()
+	t.mu.Unlock()
+	if fn != nil {
+		fn(TerminalDevice, page, true)
+	}
+}
+
+func (t *Terminal) quit() {
+	t.doneOnce.Do(func() { close(t.done) })
+}
+
+// fitWidth pads or truncates s to exactly width characters
+func fitWidth(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
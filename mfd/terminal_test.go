@@ -0,0 +1,35 @@
+package mfd
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTerminalKeys(t *testing.T) {
+	in, keys := io.Pipe()
+	var out bytes.Buffer
+	sim := NewTerminal(in, &out)
+	clicks := 0
+	if err := InitDevice(sim, testPages, func() { clicks++ }); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+
+	// scroll down, click, next page, quit
+	io.WriteString(keys, "\x1b[B\r\x1b[Cq")
+	<-sim.Done()
+
+	if clicks != 1 {
+		t.Errorf("got %d clicks, wanted 1", clicks)
+	}
+	if got := sim.lines[0]; !slices.Equal(got, []string{"B", "C", "D"}) {
+		t.Errorf("got lines %q on the first page", got)
+	}
+	screen := out.String()
+	if !strings.Contains(screen, "|Second          |") || !strings.Contains(screen, "Page 2/2") {
+		t.Errorf("second page was not drawn:\n%s", screen)
+	}
+}
@@ -0,0 +1,188 @@
+package mfd
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+//go:embed web.html
+var webPage []byte
+
+// webEvent is sent by the browser when one of the virtual MFD controls is used
+type webEvent struct {
+	Event string `json:"event"`
+}
+
+// WebServer serves a virtual MFD to browsers. Every display refresh is pushed to
+// the connected browsers over a WebSocket, and their controls are fed back into the
+// same handling as the X52 Pro page and scroll wheels.
+type WebServer struct {
+	server *http.Server
+	// Stops publishing frames to the server
+	removeListener func()
+
+	mu      sync.Mutex
+	frame   Frame
+	clients map[chan Frame]struct{}
+}
+
+// StartWebServer starts serving the virtual MFD on the given address, e.g. "127.0.0.1:8052"
+func StartWebServer(addr string) (*WebServer, error) {
+	ws := &WebServer{clients: map[chan Frame]struct{}{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(webPage)
+	})
+	mux.Handle("/ws", websocket.Server{Handler: ws.handleSocket, Handshake: ws.checkOrigin})
+	ws.server = &http.Server{Addr: addr, Handler: mux}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	ws.server.Addr = listener.Addr().String()
+
+	mu.Lock()
+	ws.frame = primaryDevice().frame()
+	mu.Unlock()
+	ws.removeListener = AddListener(ws.publish)
+
+	go func() {
+		err := ws.server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warnln("Virtual MFD web server stopped:", err)
+		}
+	}()
+	log.Infof("Serving virtual MFD on http://%s/", ws.server.Addr)
+	return ws, nil
+}
+
+// Addr returns the address the server is listening on
+func (ws *WebServer) Addr() string {
+	return ws.server.Addr
+}
+
+// Close stops the web server
+func (ws *WebServer) Close() error {
+	ws.removeListener()
+	return ws.server.Close()
+}
+
+// checkOrigin refuses WebSockets opened by pages of other sites, which could otherwise follow and
+// control the display from any page open in the browser. Only pages served from the address the
+// server listens on or from the local machine are let in.
+func (ws *WebServer) checkOrigin(cfg *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(cfg, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil {
+		return errors.New("missing Origin header")
+	}
+	listenHost, _, err := net.SplitHostPort(ws.server.Addr)
+	if err != nil {
+		return err
+	}
+	switch origin.Hostname() {
+	case listenHost, "localhost", "127.0.0.1", "::1":
+		cfg.Origin = origin
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// publish queues a frame for every connected browser, dropping frames a slow browser has not yet received
+func (ws *WebServer) publish(frame Frame) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.frame = frame
+	for client := range ws.clients {
+		select {
+		case <-client:
+		default:
+		}
+		client <- frame
+	}
+}
+
+func (ws *WebServer) handleSocket(conn *websocket.Conn) {
+	defer conn.Close()
+
+	frames := make(chan Frame, 1)
+	ws.mu.Lock()
+	frames <- ws.frame
+	ws.clients[frames] = struct{}{}
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		delete(ws.clients, frames)
+		ws.mu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		ws.receiveEvents(conn)
+		close(done)
+	}()
+
+	for {
+		select {
+		case frame := <-frames:
+			if err := websocket.JSON.Send(conn, frame); err != nil {
+				log.Debugln("Virtual MFD browser disconnected:", err)
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// receiveEvents handles the control events of a single browser until it disconnects
+func (ws *WebServer) receiveEvents(conn *websocket.Conn) {
+	defer conn.Close()
+	for {
+		var ev webEvent
+		if err := websocket.JSON.Receive(conn, &ev); err != nil {
+			return
+		}
+		log.Traceln("Virtual MFD event", ev.Event)
+		mu.Lock()
+		hdevice := primaryDevice().handle
+		mu.Unlock()
+		switch ev.Event {
+		case "select":
+			onSoftButton(hdevice, softButton_Select)
+			onSoftButton(hdevice, 0)
+		case "up":
+			onSoftButton(hdevice, softButton_Up)
+			onSoftButton(hdevice, 0)
+		case "down":
+			onSoftButton(hdevice, softButton_Down)
+			onSoftButton(hdevice, 0)
+		case "next", "prev":
+			delta := 1
+			if ev.Event == "prev" {
+				delta = -1
+			}
+			mu.Lock()
+			d := primaryDevice()
+			touchDevice(d)
+			turnPage(d, delta)
+			mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package mfd
+
+import (
+	"io"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestWebServer(t *testing.T) {
+	fake := NewFake()
+	if err := InitDevice(fake, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	UpdateDisplay(testDisplay())
+
+	ws, err := StartWebServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	conn, err := websocket.Dial("ws://"+ws.Addr()+"/ws", "", "http://"+ws.Addr()+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var frame Frame
+	if err := websocket.JSON.Receive(conn, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.PageCount != 2 || frame.Lines[0] != "A" {
+		t.Errorf("got initial frame %+v", frame)
+	}
+
+	websocket.JSON.Send(conn, webEvent{Event: "down"})
+	if err := websocket.JSON.Receive(conn, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Line != 1 || frame.Lines[0] != "B" {
+		t.Errorf("got frame %+v after scrolling down", frame)
+	}
+	if got := fake.Line(FakeDevice, 0, 0); got != "B" {
+		t.Errorf("device shows %q after scrolling down in the browser", got)
+	}
+
+	// The X52 Pro can't be told which page to show, so the browser can't turn its pages
+	websocket.JSON.Send(conn, webEvent{Event: "next"})
+	websocket.JSON.Send(conn, webEvent{Event: "up"})
+	if err := websocket.JSON.Receive(conn, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.CurrentPage != 0 || !frame.PagesLocked || frame.Lines[0] != "A" {
+		t.Errorf("got frame %+v after turning the page of the X52 Pro", frame)
+	}
+}
+
+func TestWebServerTurnsPage(t *testing.T) {
+	hostIn, display := io.Pipe()
+	displayIn, host := io.Pipe()
+	messages := readDisplay(displayIn)
+	s := NewSerial(serialPipe{hostIn, host}, "lcd", Geometry{Width: 20, Lines: 4})
+	if err := InitDevice(s, testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+	defer display.Close()
+	UpdateDisplay(testDisplay())
+	expectMessages(t, messages, "PAGE 1 2")
+
+	ws, err := StartWebServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	conn, err := websocket.Dial("ws://"+ws.Addr()+"/ws", "", "http://"+ws.Addr()+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var frame Frame
+	if err := websocket.JSON.Receive(conn, &frame); err != nil {
+		t.Fatal(err)
+	}
+
+	// The display is told to show the page turned to in the browser
+	websocket.JSON.Send(conn, webEvent{Event: "next"})
+	if err := websocket.JSON.Receive(conn, &frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.CurrentPage != 1 || frame.PagesLocked || frame.Lines[0] != "Second" {
+		t.Errorf("got frame %+v after turning the page", frame)
+	}
+	expectMessages(t, messages, "PAGE 2 2")
+}
+
+func TestWebServerRejectsOtherSites(t *testing.T) {
+	if err := InitDevice(NewFake(), testPages, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer DeInitDevice()
+
+	ws, err := StartWebServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if conn, err := websocket.Dial("ws://"+ws.Addr()+"/ws", "", "http://example.com/"); err == nil {
+		conn.Close()
+		t.Error("a page of another site opened the WebSocket")
+	}
+	conn, err := websocket.Dial("ws://"+ws.Addr()+"/ws", "", "http://localhost:8052/")
+	if err != nil {
+		t.Fatalf("a local page was refused: %v", err)
+	}
+	conn.Close()
+}
+
+func TestWebServerCloseStopsListening(t *testing.T) {
+	mu.Lock()
+	before := len(listeners)
+	mu.Unlock()
+	ws, err := StartWebServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(listeners) != before {
+		t.Errorf("%d listeners left after closing the web server, wanted %d", len(listeners), before)
+	}
+}
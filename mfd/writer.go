@@ -0,0 +1,104 @@
+package mfd
+
+import (
+	"bytes"
+	"image"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The minimum time between two writes to a device. Refreshes in between are coalesced into a single write.
+var writeInterval = 50 * time.Millisecond
+
+// scheduleWrite writes the current view of a device now, or once the write interval has passed
+// since the last write. Must be called with the lock held.
+func scheduleWrite(d *deviceState) {
+	if d.writeTimer != nil {
+		// The pending write picks up the latest state
+		return
+	}
+	wait := writeInterval - time.Since(d.lastWrite)
+	if wait <= 0 {
+		writeDevice(d)
+		return
+	}
+	d.writeTimer = time.AfterFunc(wait, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		d.writeTimer = nil
+		if d.handle == 0 || devices[d.handle] != d {
+			return
+		}
+		writeDevice(d)
+	})
+}
+
+// cancelWrite drops a pending write of a device. Must be called with the lock held.
+func cancelWrite(d *deviceState) {
+	if d.writeTimer != nil {
+		d.writeTimer.Stop()
+		d.writeTimer = nil
+	}
+	d.retrying = false
+}
+
+// forgetWrites clears what is known to be on a device, so everything is written again. Must be called with the lock held.
+func forgetWrites(d *deviceState) {
+	d.shownLines = map[uint32]map[uint32]string{}
+	d.shownImages = map[uint32]*image.RGBA{}
+}
+
+// writeDevice sends the lines or image of the current page that differ from what the device shows.
+// Must be called with the lock held.
+func writeDevice(d *deviceState) {
+	if !d.loaded || !d.pageActive || int(d.currentPage) >= len(d.pages) {
+		return
+	}
+	d.lastWrite = time.Now()
+	if d.shownLines == nil {
+		forgetWrites(d)
+	}
+	page := d.pageID()
+
+	if d.image {
+		var img *image.RGBA
+		if o := overlayFor(d.pageKey()); o != nil {
+			img = RenderImage(Page{Lines: o.Lines}, 0)
+		} else {
+			img = RenderImage(d.page(), d.currentLines[d.viewKey()])
+		}
+		if shown, ok := d.shownImages[page]; ok && bytes.Equal(shown.Pix, img.Pix) {
+			return
+		}
+		log.Debugln("Refreshing display")
+		if err := deviceCall(d, func() error { return backend.(ImageBackend).SetImage(d.handle, page, img) }); err != nil {
+			log.Debugln("Unable to set image", err)
+			return
+		}
+		d.shownImages[page] = img
+		return
+	}
+
+	shown := d.shownLines[page]
+	if shown == nil {
+		shown = map[uint32]string{}
+		d.shownLines[page] = shown
+	}
+	for l, text := range d.frame().Lines {
+		line := uint32(l)
+		if current, ok := shown[line]; ok && current == text {
+			continue
+		}
+		log.Traceln("Writing line", line, "of page", page)
+		if err := deviceCall(d, func() error { return backend.SetString(d.handle, page, line, text) }); err != nil {
+			log.Debugln("Unable to set line", line, err)
+			if d.retrying {
+				// The recovery write sends the remaining lines
+				return
+			}
+			continue
+		}
+		shown[line] = text
+	}
+}